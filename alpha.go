@@ -0,0 +1,150 @@
+package imgdiet
+
+import (
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// HasAlpha reports whether the image has an alpha channel.
+func (i *Image) HasAlpha() bool {
+	return i.reference.HasAlpha()
+}
+
+// AlphaUsed reports whether the image has an alpha channel with at least one
+// pixel that is not fully opaque.
+func (i *Image) AlphaUsed() (bool, error) {
+	if !i.reference.HasAlpha() {
+		return false, nil
+	}
+
+	opaque, ok := opaqueValue(i.reference.BandFormat())
+	if !ok {
+		// Assume the alpha channel is used when its range is unknown, so it is
+		// never dropped by mistake.
+		return true, nil
+	}
+
+	alpha, err := i.reference.ExtractBandToImage(i.reference.Bands()-1, 1)
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+	defer alpha.Close()
+
+	// The average only reaches the opaque value when every pixel is opaque.
+	average, err := alpha.Average()
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+
+	return average < opaque, nil
+}
+
+// Flatten removes the alpha channel of the image, blending its transparent
+// pixels onto the given background color, or white if nil. The background is
+// scaled to the range of the image, so it looks the same on 8-bit and 16-bit
+// images.
+//
+// Grayscale images are converted to sRGB, or 16-bit RGB for high bit depth
+// ones, so they can be blended onto colored backgrounds.
+func (i *Image) Flatten(background *Color) error {
+	if !i.reference.HasAlpha() {
+		return nil
+	}
+
+	if background == nil {
+		background = &Color{R: 255, G: 255, B: 255}
+	}
+
+	i.modified()
+
+	if i.reference.Bands() < 3 {
+		space := vips.InterpretationSRGB
+		if i.highDepth() {
+			space = vips.InterpretationRGB16
+		}
+
+		if err := i.reference.ToColorSpace(space); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	opaque, ok := opaqueValue(i.reference.BandFormat())
+	if !ok {
+		opaque = 255
+	}
+
+	scale := opaque / 255
+
+	return flatten(
+		i.reference,
+		float64(background.R)*scale,
+		float64(background.G)*scale,
+		float64(background.B)*scale,
+		opaque,
+	)
+}
+
+// DropOpaqueAlpha removes the alpha channel of the image if every pixel is
+// fully opaque, as it only takes space in the output image. It reports whether
+// the alpha channel was removed.
+func (i *Image) DropOpaqueAlpha() (bool, error) {
+	used, err := i.AlphaUsed()
+	if err != nil {
+		return false, err
+	}
+
+	if used || !i.reference.HasAlpha() {
+		return false, nil
+	}
+
+	i.modified()
+
+	if err = i.reference.ExtractBand(0, i.reference.Bands()-1); err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+
+	return true, nil
+}
+
+// handleAlpha flattens the image or drops its alpha channel as required by the
+// given Options. Images about to be encoded in a format without transparency
+// are always flattened.
+func (i *Image) handleAlpha(opts *Options) error {
+	if !i.reference.HasAlpha() {
+		return nil
+	}
+
+	if opts.Flatten || i.outputFormat(opts) == ImageTypeJPEG {
+		return i.Flatten(opts.Background)
+	}
+
+	if opts.DropOpaqueAlpha {
+		if _, err := i.DropOpaqueAlpha(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// opaqueValue returns the alpha value of a fully opaque pixel for the given
+// band format, or false if it is not known.
+func opaqueValue(format vips.BandFormat) (float64, bool) {
+	switch format {
+	case vips.BandFormatUchar:
+		return 255, true
+	case vips.BandFormatChar:
+		return 127, true
+	case vips.BandFormatUshort:
+		return 65535, true
+	case vips.BandFormatShort:
+		return 32767, true
+	case vips.BandFormatNotSet, vips.BandFormatUint, vips.BandFormatInt,
+		vips.BandFormatFloat, vips.BandFormatComplex, vips.BandFormatDouble,
+		vips.BandFormatDpComplex:
+		fallthrough
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,62 @@
+package imgdiet
+
+import (
+	"testing"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+func TestOpaqueValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		give   vips.BandFormat
+		want   float64
+		wantOK bool
+	}{
+		{
+			name:   "unsigned_char",
+			give:   vips.BandFormatUchar,
+			want:   255,
+			wantOK: true,
+		},
+		{
+			name:   "signed_char",
+			give:   vips.BandFormatChar,
+			want:   127,
+			wantOK: true,
+		},
+		{
+			name:   "unsigned_short",
+			give:   vips.BandFormatUshort,
+			want:   65535,
+			wantOK: true,
+		},
+		{
+			name:   "signed_short",
+			give:   vips.BandFormatShort,
+			want:   32767,
+			wantOK: true,
+		},
+		{
+			name:   "float",
+			give:   vips.BandFormatFloat,
+			want:   0,
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := opaqueValue(tt.give)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("opaqueValue() = %v, %t, want %v, %t", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
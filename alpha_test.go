@@ -0,0 +1,246 @@
+package imgdiet_test
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+func TestImage_AlphaUsed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		give      string
+		wantAlpha bool
+		wantUsed  bool
+	}{
+		{
+			name:      "transparent_PNG_image",
+			give:      _TestDataPath + "/" + _TestTransparentImagePNG,
+			wantAlpha: true,
+			wantUsed:  true,
+		},
+		{
+			name:      "opaque_PNG_image_with_alpha",
+			give:      _TestDataPath + "/" + _TestOpaqueAlphaImagePNG,
+			wantAlpha: true,
+			wantUsed:  false,
+		},
+		{
+			name:      "JPEG_image",
+			give:      _TestDataPath + "/" + _TestValidImageJPG,
+			wantAlpha: false,
+			wantUsed:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(tt.give)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			image, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer image.Close()
+
+			if got := image.HasAlpha(); got != tt.wantAlpha {
+				t.Errorf("expected alpha channel %t, got %t", tt.wantAlpha, got)
+			}
+
+			got, err := image.AlphaUsed()
+			if err != nil {
+				t.Fatalf("AlphaUsed() failed: %v", err)
+			}
+
+			if got != tt.wantUsed {
+				t.Errorf("expected alpha used %t, got %t", tt.wantUsed, got)
+			}
+		})
+	}
+}
+
+func TestImage_DropOpaqueAlpha(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		want bool
+	}{
+		{
+			name: "opaque_PNG_image_with_alpha",
+			give: _TestDataPath + "/" + _TestOpaqueAlphaImagePNG,
+			want: true,
+		},
+		{
+			name: "transparent_PNG_image",
+			give: _TestDataPath + "/" + _TestTransparentImagePNG,
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(tt.give)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			image, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer image.Close()
+
+			got, err := image.DropOpaqueAlpha()
+			if err != nil {
+				t.Fatalf("DropOpaqueAlpha() failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+
+			if image.HasAlpha() == tt.want {
+				t.Errorf("expected alpha channel %t, got %t", !tt.want, image.HasAlpha())
+			}
+		})
+	}
+}
+
+func TestImage_Optimize_Flatten(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		flatten   bool
+		wantAlpha bool
+	}{
+		{
+			name:      "flatten",
+			flatten:   true,
+			wantAlpha: false,
+		},
+		{
+			name:      "keep_alpha",
+			flatten:   false,
+			wantAlpha: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(_TestDataPath + "/" + _TestTransparentImagePNG)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			image, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer image.Close()
+
+			opts := imgdiet.DefaultOptions()
+			opts.Flatten = tt.flatten
+			opts.Background = &imgdiet.Color{R: 255, G: 0, B: 0}
+
+			got, err := image.Optimize(opts)
+			if err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+
+			optimized, err := imgdiet.Open(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("Open() failed on optimized image: %v", err)
+			}
+			defer optimized.Close()
+
+			if optimized.HasAlpha() != tt.wantAlpha {
+				t.Errorf("expected alpha channel %t, got %t", tt.wantAlpha, optimized.HasAlpha())
+			}
+		})
+	}
+}
+
+func TestImage_Flatten_HighDepth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give *imgdiet.Color
+		want color.NRGBA64
+	}{
+		{
+			name: "white_background",
+			give: &imgdiet.Color{R: 255, G: 255, B: 255},
+			want: color.NRGBA64{R: 65535, G: 65535, B: 65535, A: 65535},
+		},
+		{
+			name: "red_background",
+			give: &imgdiet.Color{R: 255, G: 0, B: 0},
+			want: color.NRGBA64{R: 65535, G: 0, B: 0, A: 65535},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			img := openTestImage(t, _TestDataPath+"/"+_TestTransparentHighDepthImagePNG)
+
+			if err := img.Flatten(tt.give); err != nil {
+				t.Fatalf("Flatten() failed: %v", err)
+			}
+
+			data, err := img.Optimize(&imgdiet.Options{
+				Format:        imgdiet.ImageTypePNG,
+				PreserveDepth: true,
+			})
+			if err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+
+			flattened, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("png.Decode() failed: %v", err)
+			}
+
+			// The left half of the image is fully transparent.
+			got, ok := color.NRGBA64Model.Convert(flattened.At(0, 0)).(color.NRGBA64)
+			if !ok {
+				t.Fatalf("expected a 16-bit color, got %T", flattened.At(0, 0))
+			}
+
+			if got != tt.want {
+				t.Errorf("expected background %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
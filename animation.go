@@ -0,0 +1,340 @@
+package imgdiet
+
+import (
+	"fmt"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+const (
+	// ErrInvalidFrameCount is returned when the number of frames to keep or
+	// skip in an animated image is not positive.
+	ErrInvalidFrameCount xerrors.Error = "frame count must be greater than 0"
+
+	// ErrAnimatedImage is returned when trying to apply an operation that only
+	// supports still images to an animated one.
+	ErrAnimatedImage xerrors.Error = "operation not supported on animated images"
+
+	// ErrInvalidFrameIndex is returned when selecting a frame past the last
+	// frame of an image.
+	ErrInvalidFrameIndex xerrors.Error = "frame index out of range"
+)
+
+// Metadata fields libvips uses to store the timing of animated images. They
+// are kept regardless of the metadata policy, as removing them would change
+// the speed of the animation.
+const (
+	delayField = "delay"
+	loopField  = "loop"
+)
+
+// animationFields returns the names of the metadata fields describing the
+// timing of animated images, including the ones used by older versions of
+// libvips.
+func animationFields() []string {
+	return []string{delayField, loopField, "gif-delay", "gif-loop"}
+}
+
+// Frames returns the number of frames of the image, which is 1 for still
+// images, including single pages of multi-page documents.
+func (i *Image) Frames() int {
+	return i.reference.Height() / i.reference.PageHeight()
+}
+
+// Delays returns the time each frame of an animated image is displayed for,
+// in milliseconds, or nil for still images.
+func (i *Image) Delays() []int {
+	if i.Frames() < 2 || !i.hasField(delayField) {
+		return nil
+	}
+
+	delays, err := i.reference.PageDelay()
+	if err != nil {
+		return nil
+	}
+
+	return delays
+}
+
+// Loop returns the number of times an animated image is played, where 0 means
+// forever.
+func (i *Image) Loop() int {
+	if !i.hasField(loopField) {
+		return 0
+	}
+
+	return i.reference.GetInt(loopField)
+}
+
+// DropFrames reduces the number of frames of an animated image by keeping the
+// first of every step frames, such as every other frame for a step of 2. The
+// delays of the dropped frames are added to the kept ones, so the animation
+// keeps its duration.
+func (i *Image) DropFrames(step int) error {
+	if step < 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidFrameCount, step)
+	}
+
+	frames := i.Frames()
+	if step == 1 || frames < 2 {
+		return nil
+	}
+
+	var (
+		delays  = i.Delays()
+		indexes = make([]int, 0, (frames+step-1)/step)
+		kept    = make([]int, 0, cap(indexes))
+	)
+
+	for index := 0; index < frames; index += step {
+		var delay int
+
+		for n := index; n < index+step && n < frames; n++ {
+			if n < len(delays) {
+				delay += delays[n]
+			}
+		}
+
+		indexes = append(indexes, index)
+		kept = append(kept, delay)
+	}
+
+	return i.selectFrames(indexes, kept)
+}
+
+// SelectFrame keeps only the frame at the given index, counting from 0, turning
+// an animated image into a still one. Still images can be encoded to any
+// format, such as a JPEG poster for an animated GIF.
+func (i *Image) SelectFrame(index int) error {
+	frames := i.Frames()
+	if index < 0 || index >= frames {
+		return fmt.Errorf("%w: %d of %d frames", ErrInvalidFrameIndex, index, frames)
+	}
+
+	if frames < 2 {
+		return nil
+	}
+
+	if err := i.selectFrames([]int{index}, nil); err != nil {
+		return err
+	}
+
+	return i.removeAnimation()
+}
+
+// RepresentativeFrame returns the index of the frame with the most detail,
+// measured as the entropy of its luminance histogram, for use as a poster with
+// SelectFrame. It skips over blank or faded frames often found at the start of
+// animations. Ties go to the earliest frame.
+func (i *Image) RepresentativeFrame() (int, error) {
+	var (
+		best     int
+		bestBits = -1.0
+	)
+
+	for index := 0; index < i.Frames(); index++ {
+		frame, err := i.frame(index)
+		if err != nil {
+			return 0, err
+		}
+
+		bits, err := entropy(frame)
+		frame.Close()
+
+		if err != nil {
+			return 0, err
+		}
+
+		if bits > bestBits {
+			best = index
+			bestBits = bits
+		}
+	}
+
+	return best, nil
+}
+
+// LimitFrames keeps at most the first limit frames of an animated image.
+func (i *Image) LimitFrames(limit int) error {
+	if limit < 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidFrameCount, limit)
+	}
+
+	if i.Frames() <= limit {
+		return nil
+	}
+
+	var (
+		delays  = i.Delays()
+		indexes = make([]int, 0, limit)
+		kept    = make([]int, 0, limit)
+	)
+
+	for index := 0; index < limit; index++ {
+		indexes = append(indexes, index)
+
+		if index < len(delays) {
+			kept = append(kept, delays[index])
+		}
+	}
+
+	return i.selectFrames(indexes, kept)
+}
+
+// selectFrames replaces the frames of an animated image with the ones at the
+// given indexes, in order, displayed for the given delays.
+func (i *Image) selectFrames(indexes, delays []int) error {
+	i.modified()
+
+	var (
+		pageHeight = i.reference.PageHeight()
+		frames     = make([]*vips.ImageRef, 0, len(indexes))
+	)
+
+	defer func() {
+		for _, frame := range frames {
+			frame.Close()
+		}
+	}()
+
+	for _, index := range indexes {
+		frame, err := i.frame(index)
+		if err != nil {
+			return err
+		}
+
+		frames = append(frames, frame)
+	}
+
+	animation := frames[0]
+	frames = frames[1:]
+
+	if len(frames) > 0 {
+		if err := animation.ArrayJoin(frames, 1); err != nil {
+			animation.Close()
+
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := animation.SetPageHeight(pageHeight); err != nil {
+		animation.Close()
+
+		return fmt.Errorf("%w", err)
+	}
+
+	animation.SetInt("n-pages", len(indexes))
+
+	if len(delays) == len(indexes) {
+		if err := animation.SetPageDelay(delays); err != nil {
+			animation.Close()
+
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	i.reference.Close()
+	i.reference = animation
+
+	return nil
+}
+
+// still returns an error if the image is animated, for operations which would
+// treat its frames as a single image.
+func (i *Image) still() error {
+	if i.Frames() > 1 {
+		return fmt.Errorf("%w: %d frames", ErrAnimatedImage, i.Frames())
+	}
+
+	return nil
+}
+
+// frame returns a copy of the frame at the given index.
+func (i *Image) frame(index int) (*vips.ImageRef, error) {
+	frame, err := i.reference.Copy()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	pageHeight := i.reference.PageHeight()
+
+	// Frames are stacked vertically, and ExtractArea crops each of them
+	// instead of the whole stack unless the image is seen as one page.
+	if err = frame.SetPageHeight(frame.Height()); err != nil {
+		frame.Close()
+
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if err = frame.ExtractArea(0, index*pageHeight, frame.Width(), pageHeight); err != nil {
+		frame.Close()
+
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return frame, nil
+}
+
+// entropy returns the entropy of the luminance histogram of the given image,
+// in bits, which is higher the more detail the image has.
+func entropy(image *vips.ImageRef) (float64, error) {
+	if err := image.ToColorSpace(vips.InterpretationBW); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	if err := image.ExtractBand(0, 1); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	if err := image.HistogramFind(); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	bits, err := image.HistogramEntropy()
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	return bits, nil
+}
+
+// removeAnimation removes the metadata fields describing the timing of
+// animated images.
+func (i *Image) removeAnimation() error {
+	animation := make(map[string]struct{}, len(animationFields()))
+
+	for _, field := range animationFields() {
+		animation[field] = struct{}{}
+	}
+
+	var keep []string
+
+	for _, field := range i.reference.GetFields() {
+		if _, ok := animation[field]; !ok {
+			keep = append(keep, field)
+		}
+	}
+
+	if err := i.reference.RemoveMetadata(keep...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// hasField reports whether the image has the given metadata field.
+func (i *Image) hasField(field string) bool {
+	for _, name := range i.reference.GetFields() {
+		if name == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// animatedFormat reports whether images of the given type may be animated.
+func animatedFormat(format string) bool {
+	return format == ImageTypeGIF || format == ImageTypeWebP
+}
@@ -0,0 +1,492 @@
+package imgdiet_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+func TestImage_Frames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		give       string
+		wantFrames int
+		wantDelays []int
+	}{
+		{
+			name:       "animated_GIF_image",
+			give:       _TestDataPath + "/" + _TestValidImageGIF,
+			wantFrames: 35,
+			wantDelays: []int{100, 100, 100},
+		},
+		{
+			name:       "still_PNG_image",
+			give:       _TestDataPath + "/" + _TestValidImagePNG,
+			wantFrames: 1,
+			wantDelays: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, tt.give)
+
+			if image.Frames() != tt.wantFrames {
+				t.Fatalf("expected %d frames, got %d", tt.wantFrames, image.Frames())
+			}
+
+			delays := image.Delays()
+
+			if tt.wantDelays == nil {
+				if delays != nil {
+					t.Fatalf("expected no delays, got %v", delays)
+				}
+
+				return
+			}
+
+			if len(delays) != tt.wantFrames {
+				t.Fatalf("expected %d delays, got %d", tt.wantFrames, len(delays))
+			}
+
+			for index, want := range tt.wantDelays {
+				if delays[index] != want {
+					t.Errorf("expected delay %d for frame %d, got %d", want, index, delays[index])
+				}
+			}
+
+			if image.Loop() != 0 {
+				t.Errorf("expected animation to loop forever, got %d loops", image.Loop())
+			}
+		})
+	}
+}
+
+func TestImage_DropFrames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		step       int
+		wantFrames int
+		wantDelay  int
+		err        error
+	}{
+		{
+			name:       "every_other_frame",
+			step:       2,
+			wantFrames: 18,
+			wantDelay:  200,
+			err:        nil,
+		},
+		{
+			name:       "every_frame",
+			step:       1,
+			wantFrames: 35,
+			wantDelay:  100,
+			err:        nil,
+		},
+		{
+			name: "invalid_step",
+			step: 0,
+			err:  imgdiet.ErrInvalidFrameCount,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, _TestDataPath+"/"+_TestValidImageGIF)
+			height := image.Height()
+
+			err := image.DropFrames(tt.step)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if image.Frames() != tt.wantFrames {
+				t.Fatalf("expected %d frames, got %d", tt.wantFrames, image.Frames())
+			}
+
+			if image.Height() != height {
+				t.Errorf("expected frame height %d, got %d", height, image.Height())
+			}
+
+			if delays := image.Delays(); len(delays) == 0 || delays[0] != tt.wantDelay {
+				t.Errorf("expected delay %d, got %v", tt.wantDelay, delays)
+			}
+		})
+	}
+}
+
+func TestImage_LimitFrames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		limit      int
+		wantFrames int
+		err        error
+	}{
+		{
+			name:       "fewer_frames",
+			limit:      10,
+			wantFrames: 10,
+			err:        nil,
+		},
+		{
+			name:       "more_frames_than_image",
+			limit:      100,
+			wantFrames: 35,
+			err:        nil,
+		},
+		{
+			name:  "invalid_limit",
+			limit: -1,
+			err:   imgdiet.ErrInvalidFrameCount,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, _TestDataPath+"/"+_TestValidImageGIF)
+
+			err := image.LimitFrames(tt.limit)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if image.Frames() != tt.wantFrames {
+				t.Errorf("expected %d frames, got %d", tt.wantFrames, image.Frames())
+			}
+
+			if len(image.Delays()) != tt.wantFrames {
+				t.Errorf("expected %d delays, got %d", tt.wantFrames, len(image.Delays()))
+			}
+		})
+	}
+}
+
+func TestImage_Resize_Animation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts *imgdiet.Options
+	}{
+		{
+			name: "default_options",
+			opts: imgdiet.DefaultOptions(),
+		},
+		{
+			name: "keep_metadata",
+			opts: &imgdiet.Options{
+				Quality:  80,
+				Effort:   7,
+				Bitdepth: 8,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, _TestDataPath+"/"+_TestValidImageGIF)
+
+			optimized, err := image.Resize(240, 0, tt.opts)
+			if err != nil {
+				t.Fatalf("Resize() failed: %v", err)
+			}
+
+			if image.Frames() != 35 || image.Width() != 240 || image.Height() != 135 {
+				t.Fatalf("expected 35 frames of 240x135, got %d frames of %dx%d",
+					image.Frames(), image.Width(), image.Height())
+			}
+
+			reopened, err := imgdiet.Open(bytes.NewReader(optimized))
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer reopened.Close()
+
+			if reopened.Frames() != 35 {
+				t.Fatalf("expected 35 frames, got %d", reopened.Frames())
+			}
+
+			if delays := reopened.Delays(); len(delays) != 35 || delays[0] != 100 {
+				t.Errorf("expected 35 delays of 100ms, got %v", delays)
+			}
+
+			if reopened.Loop() != 0 {
+				t.Errorf("expected animation to loop forever, got %d loops", reopened.Loop())
+			}
+		})
+	}
+}
+
+func TestImage_Rotate_Animation(t *testing.T) {
+	t.Parallel()
+
+	image := openTestImage(t, _TestDataPath+"/"+_TestValidImageGIF)
+
+	if err := image.Rotate(90, nil); !errors.Is(err, imgdiet.ErrAnimatedImage) {
+		t.Fatalf("expected error %v, got %v", imgdiet.ErrAnimatedImage, err)
+	}
+}
+
+func TestImage_Optimize_Conversion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		give       string
+		format     string
+		wantFrames int
+		wantDelay  int
+		err        error
+	}{
+		{
+			name:       "animated_GIF_to_WebP",
+			give:       _TestDataPath + "/" + _TestValidImageGIF,
+			format:     imgdiet.ImageTypeWebP,
+			wantFrames: 35,
+			wantDelay:  100,
+			err:        nil,
+		},
+		{
+			name:       "animated_WebP_to_GIF",
+			give:       _TestDataPath + "/" + _TestValidImageWebP,
+			format:     imgdiet.ImageTypeGIF,
+			wantFrames: 8,
+			wantDelay:  100,
+			err:        nil,
+		},
+		{
+			name:       "animated_WebP",
+			give:       _TestDataPath + "/" + _TestValidImageWebP,
+			format:     "",
+			wantFrames: 8,
+			wantDelay:  100,
+			err:        nil,
+		},
+		{
+			name:       "still_PNG_to_WebP",
+			give:       _TestDataPath + "/" + _TestTransparentImagePNG,
+			format:     imgdiet.ImageTypeWebP,
+			wantFrames: 1,
+			err:        nil,
+		},
+		{
+			name:   "animated_GIF_to_JPEG",
+			give:   _TestDataPath + "/" + _TestValidImageGIF,
+			format: imgdiet.ImageTypeJPEG,
+			err:    imgdiet.ErrAnimatedImage,
+		},
+		{
+			name:   "unsupported_format",
+			give:   _TestDataPath + "/" + _TestValidImagePNG,
+			format: "BMP",
+			err:    imgdiet.ErrUnsupportedImageFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, tt.give)
+
+			opts := imgdiet.DefaultOptions()
+			opts.Format = tt.format
+
+			optimized, err := image.Optimize(opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			want := tt.format
+			if want == "" {
+				want = image.Format()
+			}
+
+			if got, _ := imgdiet.DetectImageType(optimized); got != want {
+				t.Fatalf("expected %s image, got %s", want, got)
+			}
+
+			reopened, err := imgdiet.Open(bytes.NewReader(optimized))
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer reopened.Close()
+
+			if reopened.Frames() != tt.wantFrames {
+				t.Fatalf("expected %d frames, got %d", tt.wantFrames, reopened.Frames())
+			}
+
+			if tt.wantFrames < 2 {
+				return
+			}
+
+			if delays := reopened.Delays(); len(delays) != tt.wantFrames || delays[0] != tt.wantDelay {
+				t.Errorf("expected %d delays of %dms, got %v", tt.wantFrames, tt.wantDelay, delays)
+			}
+
+			if reopened.Loop() != 0 {
+				t.Errorf("expected animation to loop forever, got %d loops", reopened.Loop())
+			}
+		})
+	}
+}
+
+func TestImage_SelectFrame(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		give  string
+		index int
+		err   error
+	}{
+		{
+			name:  "animated_GIF_image",
+			give:  _TestDataPath + "/" + _TestValidImageGIF,
+			index: 5,
+			err:   nil,
+		},
+		{
+			name:  "animated_WebP_image",
+			give:  _TestDataPath + "/" + _TestValidImageWebP,
+			index: 7,
+			err:   nil,
+		},
+		{
+			name:  "still_PNG_image",
+			give:  _TestDataPath + "/" + _TestValidImagePNG,
+			index: 0,
+			err:   nil,
+		},
+		{
+			name:  "index_past_last_frame",
+			give:  _TestDataPath + "/" + _TestValidImageGIF,
+			index: 35,
+			err:   imgdiet.ErrInvalidFrameIndex,
+		},
+		{
+			name:  "negative_index",
+			give:  _TestDataPath + "/" + _TestValidImageGIF,
+			index: -1,
+			err:   imgdiet.ErrInvalidFrameIndex,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, tt.give)
+			width, height := image.Width(), image.Height()
+
+			err := image.SelectFrame(tt.index)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if image.Frames() != 1 || image.Delays() != nil {
+				t.Fatalf("expected a still image, got %d frames with delays %v", image.Frames(), image.Delays())
+			}
+
+			if image.Width() != width || image.Height() != height {
+				t.Errorf("expected %dx%d image, got %dx%d", width, height, image.Width(), image.Height())
+			}
+
+			opts := imgdiet.DefaultOptions()
+			opts.Format = imgdiet.ImageTypeJPEG
+
+			if _, err = image.Optimize(opts); err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestImage_RepresentativeFrame(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		give   string
+		frames int
+	}{
+		{
+			name:   "animated_GIF_image",
+			give:   _TestDataPath + "/" + _TestValidImageGIF,
+			frames: 35,
+		},
+		{
+			name:   "still_PNG_image",
+			give:   _TestDataPath + "/" + _TestValidImagePNG,
+			frames: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, tt.give)
+
+			index, err := image.RepresentativeFrame()
+			if err != nil {
+				t.Fatalf("RepresentativeFrame() failed: %v", err)
+			}
+
+			if index < 0 || index >= tt.frames {
+				t.Fatalf("expected frame index below %d, got %d", tt.frames, index)
+			}
+
+			if err = image.SelectFrame(index); err != nil {
+				t.Fatalf("SelectFrame() failed: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package imgdiet
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrInvalidMetadataField is returned when trying to set a metadata field
+// that is not an EXIF tag.
+const ErrInvalidMetadataField xerrors.Error = "metadata field must be an EXIF tag"
+
+// Attribution holds the authorship and licensing information stamped into an
+// image.
+type Attribution struct {
+	// Creator is the name of the author of the image. It is written to the
+	// EXIF Artist tag and the XMP dc:creator property.
+	Creator string
+
+	// Copyright is the copyright notice of the image. It is written to the
+	// EXIF Copyright tag and the XMP dc:rights property.
+	Copyright string
+
+	// License is the URL of the license the image is distributed under. It is
+	// written to the XMP xmpRights:WebStatement and cc:license properties.
+	License string
+}
+
+// Stamp writes the given Attribution into the image metadata. The stamped
+// fields are kept by Optimize even when StripMetadata is true or a
+// MetadataPolicy would remove them.
+//
+// The generated XMP packet replaces the one set by SetXMP, if any.
+func (i *Image) Stamp(a *Attribution) error {
+	if a == nil {
+		return nil
+	}
+
+	if a.Creator != "" {
+		if err := i.SetEXIF("exif-ifd0-Artist", a.Creator); err != nil {
+			return err
+		}
+	}
+
+	if a.Copyright != "" {
+		if err := i.SetEXIF("exif-ifd0-Copyright", a.Copyright); err != nil {
+			return err
+		}
+	}
+
+	i.SetXMP(attributionXMP(a))
+
+	return nil
+}
+
+// SetEXIF sets the EXIF tag with the given libvips name, such as
+// "exif-ifd0-ImageDescription", to value. The tag is kept by Optimize even
+// when StripMetadata is true or a MetadataPolicy would remove it.
+//
+// Only tags of the ASCII type, such as Artist, Copyright, ImageDescription,
+// and Software, are supported.
+func (i *Image) SetEXIF(field, value string) error {
+	if !strings.HasPrefix(field, exifFieldPrefix) {
+		return fmt.Errorf("%w: %s", ErrInvalidMetadataField, field)
+	}
+
+	// libvips drops a trailing parenthesized annotation when parsing tag
+	// values, so one is always added to keep values ending in a parenthesis
+	// intact.
+	i.modified()
+
+	length := len(value) + 1
+	i.reference.SetString(field, fmt.Sprintf("%s (ASCII, %d components, %d bytes)", value, length, length))
+
+	if i.stamped == nil {
+		i.stamped = make(map[string]struct{})
+	}
+
+	i.stamped[field] = struct{}{}
+
+	return nil
+}
+
+// SetXMP sets the XMP packet written by Optimize into JPEG and PNG images,
+// replacing the original one. The packet is kept even when StripMetadata is
+// true or a MetadataPolicy would remove it. Other formats are written without
+// it.
+func (i *Image) SetXMP(packet []byte) {
+	i.xmp = append([]byte(nil), packet...)
+}
+
+// attributionXMP returns an XMP packet describing the given Attribution.
+func attributionXMP(a *Attribution) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	buf.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	buf.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	buf.WriteString("  <rdf:Description rdf:about=\"\"\n")
+	buf.WriteString("    xmlns:dc=\"http://purl.org/dc/elements/1.1/\"\n")
+	buf.WriteString("    xmlns:xmpRights=\"http://ns.adobe.com/xap/1.0/rights/\"\n")
+	buf.WriteString("    xmlns:cc=\"http://creativecommons.org/ns#\">\n")
+
+	if a.Creator != "" {
+		fmt.Fprintf(&buf, "   <dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", html.EscapeString(a.Creator))
+	}
+
+	if a.Copyright != "" {
+		fmt.Fprintf(&buf, "   <dc:rights><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:rights>\n", html.EscapeString(a.Copyright))
+		buf.WriteString("   <xmpRights:Marked>True</xmpRights:Marked>\n")
+	}
+
+	if a.License != "" {
+		license := html.EscapeString(a.License)
+
+		fmt.Fprintf(&buf, "   <xmpRights:WebStatement>%s</xmpRights:WebStatement>\n", license)
+		fmt.Fprintf(&buf, "   <cc:license rdf:resource=\"%s\"/>\n", license)
+	}
+
+	buf.WriteString("  </rdf:Description>\n")
+	buf.WriteString(" </rdf:RDF>\n")
+	buf.WriteString("</x:xmpmeta>\n")
+	buf.WriteString("<?xpacket end=\"w\"?>")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,171 @@
+package imgdiet_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+func TestImage_Stamp(t *testing.T) {
+	t.Parallel()
+
+	attribution := &imgdiet.Attribution{
+		Creator:   "James Pond",
+		Copyright: "Copyright 2023 James Pond",
+		License:   "https://creativecommons.org/licenses/by/4.0/",
+	}
+
+	tests := []struct {
+		name    string
+		give    string
+		opts    *imgdiet.Options
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "valid_JPEG_image_with_stripped_metadata",
+			give: _TestDataPath + "/" + _TestValidImageJPG,
+			opts: imgdiet.DefaultOptions(),
+			want: []string{
+				"James Pond",
+				"Copyright 2023 James Pond",
+				"https://creativecommons.org/licenses/by/4.0/",
+			},
+			notWant: []string{"FUJIFILM", "Lightroom"},
+		},
+		{
+			name: "valid_JPEG_image_with_metadata_policy",
+			give: _TestDataPath + "/" + _TestValidImageJPG,
+			opts: &imgdiet.Options{
+				Quality:  60,
+				Metadata: imgdiet.PrivacyMetadataPolicy(),
+			},
+			want: []string{
+				"Copyright 2023 James Pond",
+				"https://creativecommons.org/licenses/by/4.0/",
+				"FUJIFILM",
+			},
+			notWant: []string{"3AA17275"},
+		},
+		{
+			name: "valid_PNG_image",
+			give: _TestDataPath + "/" + _TestValidImagePNG,
+			opts: imgdiet.DefaultOptions(),
+			want: []string{
+				"XML:com.adobe.xmp",
+				"https://creativecommons.org/licenses/by/4.0/",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(tt.give)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			image, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer image.Close()
+
+			if err = image.Stamp(attribution); err != nil {
+				t.Fatalf("Stamp() failed: %v", err)
+			}
+
+			got, err := image.Optimize(tt.opts)
+			if err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+
+			for _, want := range tt.want {
+				if !bytes.Contains(got, []byte(want)) {
+					t.Errorf("expected output to contain %q", want)
+				}
+			}
+
+			for _, notWant := range tt.notWant {
+				if bytes.Contains(got, []byte(notWant)) {
+					t.Errorf("expected output not to contain %q", notWant)
+				}
+			}
+
+			optimized, err := imgdiet.Open(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("Open() failed on optimized image: %v", err)
+			}
+			defer optimized.Close()
+
+			if !bytes.Contains(optimized.Metadata().XMP, []byte(attribution.License)) {
+				t.Errorf("expected XMP packet to contain %q", attribution.License)
+			}
+		})
+	}
+}
+
+func TestImage_SetEXIF(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		field string
+		value string
+		err   error
+	}{
+		{
+			name:  "valid_field",
+			field: "exif-ifd0-ImageDescription",
+			value: "A chair (in a hotel)",
+			err:   nil,
+		},
+		{
+			name:  "invalid_field",
+			field: "xmp-data",
+			value: "impossible-girl",
+			err:   imgdiet.ErrInvalidMetadataField,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(_TestDataPath + "/" + _TestValidImageJPG)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			image, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer image.Close()
+
+			err = image.SetEXIF(tt.field, tt.value)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got := image.Metadata().Tags[tt.field]; got != tt.value {
+				t.Errorf("expected %q, got %q", tt.value, got)
+			}
+		})
+	}
+}
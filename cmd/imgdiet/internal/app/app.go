@@ -4,20 +4,44 @@ package app
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
 	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/meta"
 	"github.com/urfave/cli/v2"
 )
 
 // Run is the entry point for the application.
 func Run() int {
+	if err := newApp().Run(os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+// newApp returns the command-line application with its flags and commands.
+func newApp() *cli.App {
 	app := cli.NewApp()
 	app.Name = meta.Name
 	app.Version = meta.Version
 	app.Usage = meta.Description
+	app.ArgsUsage = "INPUT [OUTPUT]"
 	app.HideHelpCommand = true
 
 	app.Flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:    "config",
+			Aliases: []string{"C"},
+			Usage:   "path to the configuration file (default: nearest imgdiet.toml or .imgdietrc)",
+		},
+		&cli.StringFlag{
+			Name:  "preset",
+			Usage: "use a named optimization preset (" + strings.Join(imgdiet.Presets(), ", ") + ")",
+		},
 		&cli.UintFlag{
 			Name:    "quality",
 			Aliases: []string{"q"},
@@ -48,6 +72,27 @@ func Run() int {
 			Usage:   "whether to optimize the ICC profile of the output image",
 			Value:   false,
 		},
+		&cli.StringFlag{
+			Name:    "report",
+			Aliases: []string{"r"},
+			Usage:   "print a report of the processed images to stdout in the given format (json, text)",
+		},
+		&cli.BoolFlag{
+			Name:    "dry-run",
+			Aliases: []string{"n"},
+			Usage:   "whether to report what would be saved without writing any output image",
+			Value:   false,
+		},
+		&cli.Float64Flag{
+			Name:  "check",
+			Usage: "exit with an error if any image could be reduced by more than the given percentage; implies --dry-run",
+		},
+		&cli.BoolFlag{
+			Name:    "force",
+			Aliases: []string{"f"},
+			Usage:   "whether to optimize images even if their output is up to date",
+			Value:   false,
+		},
 		&cli.BoolFlag{
 			Name:    "overwrite",
 			Aliases: []string{"w"},
@@ -58,11 +103,22 @@ func Run() int {
 
 	app.Action = OptimizeAction
 
-	if err := app.Run(os.Args); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-
-		return 1
+	app.Commands = []*cli.Command{
+		{
+			Name:      "watch",
+			Usage:     "optimize images as they are created or modified in a directory",
+			ArgsUsage: "SRC DST",
+			Flags: []cli.Flag{
+				&cli.DurationFlag{
+					Name:    "delay",
+					Aliases: []string{"d"},
+					Usage:   "how long an image must go without changes before it is optimized",
+					Value:   500 * time.Millisecond,
+				},
+			},
+			Action: WatchAction,
+		},
 	}
 
-	return 0
+	return app
 }
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"flag"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	_TestDataPath            string = "../../../../testdata"
+	_TestValidImageJPG       string = "james-pond-hotel-chair.jpg"
+	_TestTransparentImagePNG string = "transparent.png"
+	_TestBorderedImagePNG    string = "white-border.png"
+)
+
+func TestMain(m *testing.M) {
+	imgdiet.Start(nil)
+	defer imgdiet.Stop()
+
+	m.Run()
+}
+
+// newContext returns the context of the application run with the given
+// command-line arguments, without running any action.
+func newContext(t *testing.T, args ...string) *cli.Context {
+	t.Helper()
+
+	var (
+		app = newApp()
+		set = flag.NewFlagSet(app.Name, flag.ContinueOnError)
+	)
+
+	for _, f := range app.Flags {
+		if err := f.Apply(set); err != nil {
+			t.Fatalf("Apply() failed: %v", err)
+		}
+	}
+
+	if err := set.Parse(args); err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	return cli.NewContext(app, set, nil)
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// job is a single image to be optimized.
+type job struct {
+	// input is the path of the image to optimize.
+	input string
+
+	// output is the path the optimized image is written to.
+	output string
+}
+
+// jobs returns the images to optimize for the given input and output paths,
+// along with the directory the optimized images are written to. If input is a
+// directory, it is walked recursively and every supported image is mirrored
+// into the output directory. If input is a file, it is written to output, or
+// inside it if output is a directory or outputDir is true. An empty output,
+// only valid in dry-run mode, leaves the output of every job empty.
+func jobs(input, output string, outputDir bool) (string, []job, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w", err)
+	}
+
+	if !info.IsDir() {
+		if stat, statErr := os.Stat(output); output != "" && (outputDir || (statErr == nil && stat.IsDir())) {
+			output = filepath.Join(output, filepath.Base(input))
+		}
+
+		return filepath.Dir(output), []job{{input: input, output: output}}, nil
+	}
+
+	absOutput, err := filepath.Abs(output)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w", err)
+	}
+
+	var images []job
+
+	err = filepath.WalkDir(input, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			// Do not descend into the output directory when it lives inside the
+			// input directory.
+			if abs, absErr := filepath.Abs(path); absErr == nil && output != "" && path != input && abs == absOutput {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !entry.Type().IsRegular() || !supported(path) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(input, path)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		image := job{
+			input: path,
+		}
+
+		if output != "" {
+			image.output = filepath.Join(output, rel)
+		}
+
+		images = append(images, image)
+
+		return nil
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("%w", err)
+	}
+
+	return output, images, nil
+}
+
+// supported reports whether the file at path has the extension of an image
+// format supported by imgdiet.
+func supported(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".tif", ".tiff", ".jxl":
+		return true
+	default:
+		return false
+	}
+}
@@ -1,11 +1,18 @@
 package app
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"git.sr.ht/~jamesponddotco/imgdiet-go"
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/config"
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/manifest"
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/meta"
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/report"
 	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
 	"github.com/urfave/cli/v2"
 )
@@ -17,71 +24,282 @@ const (
 	// ErrNotEnoughArguments is the error returned when there are not enough
 	// arguments.
 	ErrNotEnoughArguments xerrors.Error = "not enough arguments; expected input and output"
+
+	// ErrNotOptimized is the error returned in check mode when an image could
+	// be reduced by more than the allowed percentage.
+	ErrNotOptimized xerrors.Error = "image is not optimized"
 )
 
+// mode defines how processed images are handled.
+type mode struct {
+	// cache is the manifest of previously optimized images. It is nil in
+	// dry-run mode.
+	cache *manifest.Manifest
+
+	// overwrite defines whether already existing output images are replaced.
+	overwrite bool
+
+	// dryRun defines whether optimized images are discarded instead of written.
+	dryRun bool
+
+	// force defines whether images are optimized even when the manifest says
+	// their output is up to date.
+	force bool
+}
+
 // OptimizeAction is the action for the optimize command.
 func OptimizeAction(c *cli.Context) error {
-	if c.NArg() < 2 {
-		if err := cli.ShowAppHelp(c); err != nil {
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	var (
+		input     = c.Args().Get(0)
+		output    = c.Args().Get(1)
+		outputDir = false
+		check     = c.IsSet("check")
+		run       = mode{
+			overwrite: c.Bool("overwrite"),
+			dryRun:    c.Bool("dry-run") || check,
+			force:     c.Bool("force"),
+		}
+	)
+
+	if output == "" {
+		output = cfg.Output
+		outputDir = true
+	}
+
+	if input == "" || (output == "" && !run.dryRun) {
+		if err = cli.ShowAppHelp(c); err != nil {
 			return fmt.Errorf("%w", err)
 		}
 
 		return ErrNotEnoughArguments
 	}
 
+	var summary *report.Report
+
+	if format := c.String("report"); format != "" || run.dryRun {
+		if format == "" {
+			format = report.FormatText
+		}
+
+		summary, err = report.New(format)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	root, images, err := jobs(input, output, outputDir)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if !run.dryRun {
+		run.cache, err = manifest.Load(root)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	imgdiet.Start(cfg.Config())
+	defer imgdiet.Stop()
+
+	var failed []error
+
+	for _, image := range images {
+		record, processErr := process(c, cfg, image, run)
+		if processErr != nil {
+			processErr = fmt.Errorf("%s: %w", image.input, processErr)
+			record.Error = processErr.Error()
+			failed = append(failed, processErr)
+		}
+
+		if summary != nil {
+			summary.Add(record)
+		}
+
+		if check && processErr == nil {
+			if checkErr := checkOptimized(record, c.Float64("check")); checkErr != nil {
+				failed = append(failed, checkErr)
+			}
+		}
+	}
+
+	if run.cache != nil {
+		if err = run.cache.Save(); err != nil {
+			failed = append(failed, fmt.Errorf("%w", err))
+		}
+	}
+
+	if summary != nil {
+		if err = summary.Write(os.Stdout); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return errors.Join(failed...)
+}
+
+// process resolves the Options for the given job and optimizes its image,
+// returning a record of the outcome.
+func process(c *cli.Context, cfg *config.File, j job, run mode) (*report.Record, error) {
 	var (
-		input  = c.Args().Get(0)
-		output = c.Args().Get(1)
-		dir    = filepath.Dir(output)
-		opts   = &imgdiet.Options{
-			Quality:            c.Uint("quality"),
-			Compression:        c.Uint("compression"),
-			QuantTable:         3,
-			OptimizeCoding:     true,
-			Interlaced:         c.Bool("interlace"),
-			StripMetadata:      c.Bool("strip"),
-			OptimizeICCProfile: c.Bool("optimize-icc-profile"),
-			TrellisQuant:       true,
-			OvershootDeringing: true,
-			OptimizeScans:      true,
+		start  = time.Now()
+		record = &report.Record{
+			Input:  j.input,
+			Output: j.output,
 		}
 	)
 
-	imgdiet.Start(nil)
-	defer imgdiet.Stop()
+	defer func() {
+		record.Duration = time.Since(start).Milliseconds()
+	}()
 
-	data, err := os.Open(input)
+	opts, err := options(c, cfg, j.input)
+	if err != nil {
+		return record, fmt.Errorf("%w", err)
+	}
+
+	if err = optimize(j, opts, run, record); err != nil {
+		return record, fmt.Errorf("%w", err)
+	}
+
+	return record, nil
+}
+
+// optimize optimizes a single image with the given Options and, unless running
+// in dry-run mode, writes the result to the job's output path, filling in the
+// given record along the way. Images whose output is up to date according to
+// the manifest are skipped.
+func optimize(j job, opts *imgdiet.Options, run mode, record *report.Record) error {
+	data, err := os.ReadFile(j.input)
 	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
-	defer data.Close()
 
-	image, err := imgdiet.Open(data)
+	var entry *manifest.Entry
+
+	if run.cache != nil {
+		entry, err = newEntry(data, opts)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		fresh, freshErr := run.cache.Fresh(j.output, entry)
+		if freshErr != nil {
+			return fmt.Errorf("%w", freshErr)
+		}
+
+		if fresh && !run.force {
+			record.Size = imgdiet.DetectImageSize(data)
+
+			return skip(j, record)
+		}
+	}
+
+	if _, err = os.Stat(j.output); !run.dryRun && !os.IsNotExist(err) && !run.overwrite {
+		return fmt.Errorf("%w: %s", ErrFileExists, j.output)
+	}
+
+	image, err := imgdiet.Open(bytes.NewReader(data))
 	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
 	defer image.Close()
 
+	record.Size = image.Size()
+	record.Format = image.Format()
+	record.Width = image.Width()
+	record.Height = image.Height()
+
 	optimizedImage, err := image.Optimize(opts)
 	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
-	if err = os.MkdirAll(dir, 0o755); err != nil {
+	record.Saved = image.Saved()
+	record.Savings = report.Savings(record.Size, record.Saved)
+
+	if run.dryRun {
+		return nil
+	}
+
+	if err = write(j.output, optimizedImage); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if run.cache != nil {
+		entry.Output = manifest.Hash(optimizedImage)
+
+		if err = run.cache.Set(j.output, entry); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkOptimized returns an error if the image described by the given record
+// could be reduced by more than the given percentage.
+func checkOptimized(record *report.Record, threshold float64) error {
+	if record.Savings <= threshold {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: %s could be reduced by %.2f%%",
+		ErrNotOptimized,
+		record.Input,
+		record.Savings,
+	)
+}
+
+// newEntry returns the manifest entry for the given input image and Options,
+// without the output hash.
+func newEntry(data []byte, opts *imgdiet.Options) (*manifest.Entry, error) {
+	options, err := manifest.HashOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return &manifest.Entry{
+		Input:   manifest.Hash(data),
+		Options: options,
+		Version: meta.Version,
+	}, nil
+}
+
+// skip fills in the given record for an image whose output is up to date.
+func skip(j job, record *report.Record) error {
+	info, err := os.Stat(j.output)
+	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
-	if _, err = os.Stat(output); !os.IsNotExist(err) && !c.Bool("overwrite") {
-		return fmt.Errorf("%w: %s", ErrFileExists, output)
+	record.Skipped = true
+	record.Saved = info.Size()
+	record.Savings = report.Savings(record.Size, record.Saved)
+
+	return nil
+}
+
+// write writes the given data to the file at path, creating its parent
+// directories as needed.
+func write(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("%w", err)
 	}
 
-	file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
 	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
 	defer file.Close()
 
-	if _, err = file.Write(optimizedImage); err != nil {
+	if _, err = file.Write(data); err != nil {
 		return fmt.Errorf("%w", err)
 	}
 
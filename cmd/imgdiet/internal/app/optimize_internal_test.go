@@ -0,0 +1,219 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/manifest"
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/report"
+)
+
+func TestOptimize_Report(t *testing.T) {
+	t.Parallel()
+
+	var (
+		images = []string{_TestTransparentImagePNG, _TestBorderedImagePNG, _TestValidImageJPG}
+		sizes  = make(map[string]int64, len(images))
+		total  int64
+	)
+
+	summary, err := report.New(report.FormatJSON)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	for _, name := range images {
+		path := filepath.Join(_TestDataPath, name)
+
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			t.Fatalf("Stat() failed: %v", statErr)
+		}
+
+		sizes[path] = info.Size()
+		total += info.Size()
+
+		record := &report.Record{
+			Input: path,
+		}
+
+		if err = optimize(job{input: path}, imgdiet.DefaultOptions(), mode{dryRun: true}, record); err != nil {
+			t.Fatalf("optimize() failed: %v", err)
+		}
+
+		summary.Add(record)
+	}
+
+	var buf bytes.Buffer
+
+	if err = summary.Write(&buf); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	var got report.Report
+
+	if err = json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+
+	for _, record := range got.Files {
+		want := sizes[record.Input]
+
+		if record.Size != want {
+			t.Errorf("%s: size = %d, want %d", record.Input, record.Size, want)
+		}
+
+		if savings := report.Savings(want, record.Saved); record.Savings != savings {
+			t.Errorf("%s: savings = %.2f, want %.2f", record.Input, record.Savings, savings)
+		}
+	}
+
+	if got.Totals.Size != total {
+		t.Errorf("totals size = %d, want %d", got.Totals.Size, total)
+	}
+}
+
+func TestCheckOptimized(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		give      string
+		optimized bool
+		threshold float64
+		err       error
+	}{
+		{
+			name:      "already_optimized_small_PNG_image",
+			give:      _TestTransparentImagePNG,
+			optimized: true,
+			threshold: 5,
+			err:       nil,
+		},
+		{
+			name:      "already_optimized_PNG_image",
+			give:      _TestBorderedImagePNG,
+			optimized: true,
+			threshold: 5,
+			err:       nil,
+		},
+		{
+			name:      "unoptimized_JPEG_image",
+			give:      _TestValidImageJPG,
+			optimized: false,
+			threshold: 5,
+			err:       ErrNotOptimized,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				opts = imgdiet.DefaultOptions()
+				path = filepath.Join(_TestDataPath, tt.give)
+			)
+
+			if tt.optimized {
+				optimized := filepath.Join(t.TempDir(), tt.give)
+
+				if err := optimize(job{input: path, output: optimized}, opts, mode{}, &report.Record{}); err != nil {
+					t.Fatalf("optimize() failed: %v", err)
+				}
+
+				path = optimized
+			}
+
+			record := &report.Record{
+				Input: path,
+			}
+
+			if err := optimize(job{input: path}, opts, mode{dryRun: true}, record); err != nil {
+				t.Fatalf("optimize() failed: %v", err)
+			}
+
+			if err := checkOptimized(record, tt.threshold); !errors.Is(err, tt.err) {
+				t.Errorf("checkOptimized() = %v, want %v (%.2f%% savings)", err, tt.err, record.Savings)
+			}
+		})
+	}
+}
+
+func TestOptimize_Manifest(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		force bool
+		want  bool
+	}{
+		{
+			name:  "skip_up_to_date_output",
+			force: false,
+			want:  true,
+		},
+		{
+			name:  "force",
+			force: true,
+			want:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				dir = t.TempDir()
+				j   = job{
+					input:  filepath.Join(_TestDataPath, _TestBorderedImagePNG),
+					output: filepath.Join(dir, _TestBorderedImagePNG),
+				}
+				opts = imgdiet.DefaultOptions()
+			)
+
+			for run := 0; run < 2; run++ {
+				cache, err := manifest.Load(dir)
+				if err != nil {
+					t.Fatalf("Load() failed: %v", err)
+				}
+
+				record := &report.Record{
+					Input: j.input,
+				}
+
+				err = optimize(j, opts, mode{cache: cache, overwrite: true, force: tt.force}, record)
+				if err != nil {
+					t.Fatalf("optimize() failed: %v", err)
+				}
+
+				if err = cache.Save(); err != nil {
+					t.Fatalf("Save() failed: %v", err)
+				}
+
+				if want := run == 1 && tt.want; record.Skipped != want {
+					t.Fatalf("run %d: Skipped = %v, want %v", run+1, record.Skipped, want)
+				}
+
+				info, err := os.Stat(j.output)
+				if err != nil {
+					t.Fatalf("Stat() failed: %v", err)
+				}
+
+				if record.Saved != info.Size() {
+					t.Errorf("run %d: Saved = %d, want %d", run+1, record.Saved, info.Size())
+				}
+			}
+		})
+	}
+}
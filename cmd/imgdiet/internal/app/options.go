@@ -0,0 +1,137 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/config"
+	"github.com/urfave/cli/v2"
+)
+
+// loadConfig loads the configuration file given on the command line or, if
+// none was given, the first one found walking up from the working directory.
+// An empty configuration is returned when no file is found.
+func loadConfig(c *cli.Context) (*config.File, error) {
+	path := c.String("config")
+
+	if path == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		path, err = config.Find(wd)
+		if errors.Is(err, config.ErrNotFound) {
+			return &config.File{}, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// options builds the Options used to optimize the image at the given path.
+// Settings are applied in order of precedence: the flag defaults first, then
+// the configuration file and its matching overrides, and finally the flags
+// explicitly set by the user.
+//
+// The --preset flag replaces the flag defaults and the preset of the
+// configuration file, but not its other options or overrides, so that files
+// matching an override keep their settings.
+func options(c *cli.Context, cfg *config.File, path string) (*imgdiet.Options, error) {
+	opts, err := baseOptions(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.IsSet("preset") {
+		file := *cfg
+		file.Preset = ""
+		cfg = &file
+	}
+
+	opts, err = cfg.Resolve(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	opts, err = flagOptions(c).Apply(opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return opts, nil
+}
+
+// baseOptions returns the options the configuration file is applied to, which
+// are the preset given on the command line or, if none was given, the flag
+// defaults.
+func baseOptions(c *cli.Context) (*imgdiet.Options, error) {
+	if c.IsSet("preset") {
+		opts, err := imgdiet.Preset(c.String("preset"))
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		return opts, nil
+	}
+
+	opts := &imgdiet.Options{
+		Quality:            c.Uint("quality"),
+		Compression:        c.Uint("compression"),
+		QuantTable:         3,
+		OptimizeCoding:     true,
+		Interlaced:         c.Bool("interlace"),
+		StripMetadata:      c.Bool("strip"),
+		OptimizeICCProfile: c.Bool("optimize-icc-profile"),
+		AutoOrient:         true,
+		TrellisQuant:       true,
+		OvershootDeringing: true,
+		OptimizeScans:      true,
+	}
+
+	return opts, nil
+}
+
+// flagOptions returns the options explicitly set on the command line, other
+// than the preset, which is handled by baseOptions.
+func flagOptions(c *cli.Context) *config.Options {
+	var opts config.Options
+
+	if c.IsSet("quality") {
+		quality := c.Uint("quality")
+		opts.Quality = &quality
+	}
+
+	if c.IsSet("compression") {
+		compression := c.Uint("compression")
+		opts.Compression = &compression
+	}
+
+	if c.IsSet("interlace") {
+		interlace := c.Bool("interlace")
+		opts.Interlace = &interlace
+	}
+
+	if c.IsSet("strip") {
+		strip := c.Bool("strip")
+		opts.Strip = &strip
+	}
+
+	if c.IsSet("optimize-icc-profile") {
+		optimizeICCProfile := c.Bool("optimize-icc-profile")
+		opts.OptimizeICCProfile = &optimizeICCProfile
+	}
+
+	return &opts
+}
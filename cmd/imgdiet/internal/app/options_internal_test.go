@@ -0,0 +1,107 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/config"
+)
+
+func TestOptions_Preset(t *testing.T) {
+	t.Parallel()
+
+	const content = `
+preset = "web"
+
+[[override]]
+pattern = "icons/**"
+preset = "lossless"
+
+[[override]]
+pattern = "photos/**"
+quality = 70
+`
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, config.FileName)
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	cfg, loadErr := config.Load(path)
+	if loadErr != nil {
+		t.Fatalf("Load() failed: %v", loadErr)
+	}
+
+	preset := func(name string, quality uint) *imgdiet.Options {
+		opts, presetErr := imgdiet.Preset(name)
+		if presetErr != nil {
+			t.Fatalf("Preset() failed: %v", presetErr)
+		}
+
+		if quality > 0 {
+			opts.Quality = quality
+		}
+
+		return opts
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		give string
+		want *imgdiet.Options
+	}{
+		{
+			name: "configuration_preset",
+			args: nil,
+			give: "banner.jpg",
+			want: preset(imgdiet.PresetWeb, 0),
+		},
+		{
+			name: "flag_preset_replaces_configuration_preset",
+			args: []string{"--preset", imgdiet.PresetPrivacy},
+			give: "banner.jpg",
+			want: preset(imgdiet.PresetPrivacy, 0),
+		},
+		{
+			name: "flag_preset_keeps_preset_override",
+			args: []string{"--preset", imgdiet.PresetPrivacy},
+			give: "icons/logo.png",
+			want: preset(imgdiet.PresetLossless, 0),
+		},
+		{
+			name: "flag_preset_keeps_option_override",
+			args: []string{"--preset", imgdiet.PresetPrivacy},
+			give: "photos/beach.jpg",
+			want: preset(imgdiet.PresetPrivacy, 70),
+		},
+		{
+			name: "flag_option_overrides_everything",
+			args: []string{"--preset", imgdiet.PresetPrivacy, "--quality", "50"},
+			give: "photos/beach.jpg",
+			want: preset(imgdiet.PresetPrivacy, 50),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := options(newContext(t, tt.args...), cfg, filepath.Join(dir, tt.give))
+			if err != nil {
+				t.Fatalf("options() failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("options() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
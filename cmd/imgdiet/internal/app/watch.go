@@ -0,0 +1,370 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/config"
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/manifest"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli/v2"
+)
+
+// ErrNotDirectory is the error returned when the directory to watch is not a
+// directory.
+const ErrNotDirectory xerrors.Error = "not a directory"
+
+// timer is a debounce timer, as returned by time.AfterFunc.
+type timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// afterFunc starts a debounce timer calling f once d elapses.
+func afterFunc(d time.Duration, f func()) timer {
+	return time.AfterFunc(d, f)
+}
+
+// watcher mirrors the images of a source directory into a destination
+// directory, optimizing them as they are created or modified.
+type watcher struct {
+	// c is the context of the watch command.
+	c *cli.Context
+
+	// cfg is the configuration file in use.
+	cfg *config.File
+
+	// notify delivers the file system events for the source directory.
+	notify *fsnotify.Watcher
+
+	// timers holds the pending debounce timer of each changed image. Entries
+	// are removed by the timers themselves when they expire.
+	timers map[string]timer
+
+	// after starts the debounce timers.
+	after func(d time.Duration, f func()) timer
+
+	// ready receives the images whose debounce timer expired.
+	ready chan string
+
+	// src is the absolute path of the directory being watched.
+	src string
+
+	// dst is the absolute path of the directory optimized images are written
+	// to.
+	dst string
+
+	// run defines how processed images are handled.
+	run mode
+
+	// delay is how long an image must go without changes before it is
+	// optimized.
+	delay time.Duration
+
+	// mu protects timers.
+	mu sync.Mutex
+}
+
+// WatchAction is the action for the watch command.
+func WatchAction(c *cli.Context) error {
+	if c.NArg() < 2 {
+		if err := cli.ShowSubcommandHelp(c); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return ErrNotEnoughArguments
+	}
+
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	src, err := filepath.Abs(c.Args().Get(0))
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	dst, err := filepath.Abs(c.Args().Get(1))
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s", ErrNotDirectory, src)
+	}
+
+	cache, err := manifest.Load(dst)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	notify, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer notify.Close()
+
+	imgdiet.Start(cfg.Config())
+	defer imgdiet.Stop()
+
+	w := &watcher{
+		c:      c,
+		cfg:    cfg,
+		notify: notify,
+		timers: make(map[string]timer),
+		after:  afterFunc,
+		ready:  make(chan string),
+		src:    src,
+		dst:    dst,
+		run: mode{
+			cache:     cache,
+			overwrite: true,
+			force:     c.Bool("force"),
+		},
+		delay: c.Duration("delay"),
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err = w.add(ctx, src); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return w.loop(ctx)
+}
+
+// loop handles file system events until the given context is canceled.
+func (w *watcher) loop(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			if err := w.run.cache.Save(); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			return nil
+		case event, ok := <-w.notify.Events:
+			if !ok {
+				return nil
+			}
+
+			w.handle(ctx, event)
+		case err, ok := <-w.notify.Errors:
+			if !ok {
+				return nil
+			}
+
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		case path := <-w.ready:
+			w.optimize(path)
+		}
+	}
+}
+
+// handle reacts to a single file system event.
+func (w *watcher) handle(ctx context.Context, event fsnotify.Event) {
+	switch {
+	case event.Has(fsnotify.Create):
+		info, err := os.Stat(event.Name)
+		if err != nil {
+			return
+		}
+
+		if info.IsDir() {
+			if err = w.add(ctx, event.Name); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			}
+
+			return
+		}
+
+		w.schedule(ctx, event.Name)
+	case event.Has(fsnotify.Write):
+		w.schedule(ctx, event.Name)
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		w.cancel(event.Name)
+
+		if err := w.remove(event.Name); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+	}
+}
+
+// add watches the given directory and its subdirectories, and schedules every
+// image already inside them to be optimized, so images copied along with a
+// directory are not missed.
+func (w *watcher) add(ctx context.Context, dir string) error {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			// Do not watch the destination directory when it lives inside the
+			// source directory, or every optimized image would trigger an event.
+			if path == w.dst {
+				return filepath.SkipDir
+			}
+
+			if err = w.notify.Add(path); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			return nil
+		}
+
+		if entry.Type().IsRegular() {
+			w.schedule(ctx, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// schedule schedules the image at path to be optimized once it goes without
+// changes for the configured delay, so partially written files are not
+// processed.
+func (w *watcher) schedule(ctx context.Context, path string) {
+	if !supported(path) {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// A timer that already expired may still be waiting to deliver the image,
+	// and resetting it would deliver the image a second time.
+	if pending, ok := w.timers[path]; ok && pending.Stop() {
+		pending.Reset(w.delay)
+
+		return
+	}
+
+	var pending timer
+
+	pending = w.after(w.delay, func() {
+		w.mu.Lock()
+
+		if w.timers[path] == pending {
+			delete(w.timers, path)
+		}
+
+		w.mu.Unlock()
+
+		select {
+		case w.ready <- path:
+		case <-ctx.Done():
+		}
+	})
+
+	w.timers[path] = pending
+}
+
+// cancel stops the pending debounce timer of the image at path, if any.
+func (w *watcher) cancel(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if pending, ok := w.timers[path]; ok {
+		pending.Stop()
+
+		delete(w.timers, path)
+	}
+}
+
+// optimize optimizes the image at path into the destination directory.
+func (w *watcher) optimize(path string) {
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+
+	output, err := w.output(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+
+		return
+	}
+
+	record, err := process(w.c, w.cfg, job{input: path, output: output}, w.run)
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	fmt.Fprintln(w.c.App.Writer, record)
+
+	if err = w.run.cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+}
+
+// remove removes the optimized counterpart of the given image or directory
+// from the destination directory.
+func (w *watcher) remove(path string) error {
+	if path == w.src {
+		return nil
+	}
+
+	output, err := w.output(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(output)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if !info.IsDir() && !supported(path) {
+		return nil
+	}
+
+	if err = os.RemoveAll(output); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = w.run.cache.Delete(output); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = w.run.cache.Save(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// output returns the path of the optimized counterpart of the given path.
+func (w *watcher) output(path string) (string, error) {
+	rel, err := filepath.Rel(w.src, path)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+
+	return filepath.Join(w.dst, rel), nil
+}
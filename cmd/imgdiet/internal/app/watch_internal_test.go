@@ -0,0 +1,254 @@
+package app
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/manifest"
+	"github.com/fsnotify/fsnotify"
+)
+
+// fakeTimer is a debounce timer fired by the test instead of the clock.
+type fakeTimer struct {
+	f      func()
+	active bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	active := t.active
+	t.active = false
+
+	return active
+}
+
+func (t *fakeTimer) Reset(_ time.Duration) bool {
+	active := t.active
+	t.active = true
+
+	return active
+}
+
+// expire marks the timer as expired without running its function yet, as
+// happens right before time.AfterFunc runs it.
+func (t *fakeTimer) expire() {
+	t.active = false
+}
+
+// fire expires the timer and runs its function.
+func (t *fakeTimer) fire() {
+	t.active = false
+	t.f()
+}
+
+func TestWatcher_Schedule(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		// expire defines whether the first timer expires before the burst of
+		// events starts, while the image it delivers is not received yet.
+		expire bool
+		want   int
+	}{
+		{
+			name:   "burst",
+			expire: false,
+			want:   1,
+		},
+		{
+			name:   "burst_after_expired_timer",
+			expire: true,
+			want:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				ctx    = context.Background()
+				path   = "image.png"
+				timers []*fakeTimer
+				w      = &watcher{
+					timers: make(map[string]timer),
+					after: func(_ time.Duration, f func()) timer {
+						pending := &fakeTimer{f: f, active: true}
+						timers = append(timers, pending)
+
+						return pending
+					},
+					ready: make(chan string, 4),
+				}
+			)
+
+			w.schedule(ctx, path)
+
+			if tt.expire {
+				timers[0].expire()
+			}
+
+			for i := 0; i < 20; i++ {
+				w.schedule(ctx, path)
+			}
+
+			for _, pending := range timers {
+				pending.fire()
+			}
+
+			if got := len(w.ready); got != tt.want {
+				t.Errorf("image delivered %d times, want %d", got, tt.want)
+			}
+
+			if len(w.timers) != 0 {
+				t.Errorf("%d timers left pending, want 0", len(w.timers))
+			}
+		})
+	}
+}
+
+func TestWatcher_Handle_Remove(t *testing.T) {
+	t.Parallel()
+
+	outputs := []string{"logo.png", "photos/beach.jpg", "photos/city.jpg", "notes.txt"}
+
+	tests := []struct {
+		name string
+		give string
+		want []string
+	}{
+		{
+			name: "image",
+			give: "photos/beach.jpg",
+			want: []string{"logo.png", "notes.txt", "photos/city.jpg"},
+		},
+		{
+			name: "directory",
+			give: "photos",
+			want: []string{"logo.png", "notes.txt"},
+		},
+		{
+			name: "unsupported_file",
+			give: "notes.txt",
+			want: []string{"logo.png", "notes.txt", "photos/beach.jpg", "photos/city.jpg"},
+		},
+		{
+			name: "unknown_image",
+			give: "icons/logo.png",
+			want: []string{"logo.png", "notes.txt", "photos/beach.jpg", "photos/city.jpg"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				src = t.TempDir()
+				dst = t.TempDir()
+			)
+
+			cache, err := manifest.Load(dst)
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+
+			for _, name := range outputs {
+				output := filepath.Join(dst, name)
+
+				if err = write(output, []byte(name)); err != nil {
+					t.Fatalf("write() failed: %v", err)
+				}
+
+				if supported(name) {
+					if err = cache.Set(output, &manifest.Entry{Output: manifest.Hash([]byte(name))}); err != nil {
+						t.Fatalf("Set() failed: %v", err)
+					}
+				}
+			}
+
+			if err = cache.Save(); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+
+			var (
+				path    = filepath.Join(src, tt.give)
+				pending = &fakeTimer{active: true}
+				w       = &watcher{
+					timers: map[string]timer{path: pending},
+					src:    src,
+					dst:    dst,
+					run:    mode{cache: cache, overwrite: true},
+				}
+			)
+
+			w.handle(context.Background(), fsnotify.Event{Name: path, Op: fsnotify.Remove})
+
+			if pending.active || len(w.timers) != 0 {
+				t.Error("pending timer of the removed path was not canceled")
+			}
+
+			var got []string
+
+			err = filepath.WalkDir(dst, func(name string, entry fs.DirEntry, walkErr error) error {
+				if walkErr != nil {
+					return walkErr
+				}
+
+				if !entry.IsDir() && entry.Name() != manifest.FileName {
+					rel, relErr := filepath.Rel(dst, name)
+					if relErr != nil {
+						return relErr
+					}
+
+					got = append(got, filepath.ToSlash(rel))
+				}
+
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("WalkDir() failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("output files = %v, want %v", got, tt.want)
+			}
+
+			// The manifest must be saved without the entries of the removed
+			// images.
+			saved, err := manifest.Load(dst)
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+
+			entries := make([]string, 0, len(saved.Entries))
+
+			for name := range saved.Entries {
+				entries = append(entries, name)
+			}
+
+			sort.Strings(entries)
+
+			var want []string
+
+			for _, name := range tt.want {
+				if supported(name) {
+					want = append(want, name)
+				}
+			}
+
+			if !reflect.DeepEqual(entries, want) {
+				t.Errorf("manifest entries = %v, want %v", entries, want)
+			}
+		})
+	}
+}
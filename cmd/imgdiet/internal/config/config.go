@@ -0,0 +1,269 @@
+// Package config implements discovery and parsing of the configuration file
+// used by the application.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"github.com/BurntSushi/toml"
+)
+
+// List of configuration file names the application looks for, in order of
+// preference.
+const (
+	FileName   string = "imgdiet.toml"
+	RCFileName string = ".imgdietrc"
+)
+
+const (
+	// ErrNotFound is returned when no configuration file could be found.
+	ErrNotFound xerrors.Error = "configuration file not found"
+
+	// ErrUnknownKey is returned when the configuration file contains keys the
+	// application does not understand.
+	ErrUnknownKey xerrors.Error = "unknown configuration key"
+
+	// ErrInvalidPattern is returned when an override has an empty or malformed
+	// glob pattern.
+	ErrInvalidPattern xerrors.Error = "invalid override pattern"
+)
+
+// Options defines the optimization settings that can be set in the
+// configuration file. Fields left unset do not change the options they are
+// applied to.
+type Options struct {
+	// Quality overrides imgdiet.Options.Quality.
+	Quality *uint `toml:"quality"`
+
+	// Compression overrides imgdiet.Options.Compression.
+	Compression *uint `toml:"compression"`
+
+	// Interlace overrides imgdiet.Options.Interlaced.
+	Interlace *bool `toml:"interlace"`
+
+	// Strip overrides imgdiet.Options.StripMetadata.
+	Strip *bool `toml:"strip"`
+
+	// OptimizeICCProfile overrides imgdiet.Options.OptimizeICCProfile.
+	OptimizeICCProfile *bool `toml:"optimize-icc-profile"`
+
+	// Preset is the name of the preset used as the starting point for the
+	// options, replacing whatever options came before it.
+	Preset string `toml:"preset"`
+}
+
+// Apply applies the settings defined in o on top of the given options and
+// returns the result.
+func (o *Options) Apply(opts *imgdiet.Options) (*imgdiet.Options, error) {
+	if o.Preset != "" {
+		preset, err := imgdiet.Preset(o.Preset)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		opts = preset
+	}
+
+	if o.Quality != nil {
+		opts.Quality = *o.Quality
+	}
+
+	if o.Compression != nil {
+		opts.Compression = *o.Compression
+	}
+
+	if o.Interlace != nil {
+		opts.Interlaced = *o.Interlace
+	}
+
+	if o.Strip != nil {
+		opts.StripMetadata = *o.Strip
+	}
+
+	if o.OptimizeICCProfile != nil {
+		opts.OptimizeICCProfile = *o.OptimizeICCProfile
+	}
+
+	return opts, nil
+}
+
+// Override defines options applied only to the files matching a glob pattern.
+type Override struct {
+	// Pattern is a slash-separated glob pattern, relative to the directory of
+	// the configuration file. A "**" path segment matches any number of
+	// directories.
+	Pattern string `toml:"pattern"`
+
+	Options
+}
+
+// Library defines the settings for the libvips library.
+type Library struct {
+	// Cache overrides imgdiet.Config.Cache.
+	Cache *uint64 `toml:"cache"`
+
+	// Concurrency overrides imgdiet.Config.MaxConcurrency.
+	Concurrency *int `toml:"concurrency"`
+}
+
+// File represents a configuration file.
+type File struct {
+	// Options holds the default options for every file.
+	Options
+
+	// Library holds the settings for the libvips library.
+	Library Library `toml:"libvips"`
+
+	// Output is the directory optimized images are written to when no output
+	// is given on the command line. Relative paths are resolved against the
+	// directory of the configuration file.
+	Output string `toml:"output"`
+
+	// dir is the absolute path of the directory containing the file.
+	dir string
+
+	// Overrides holds the per-pattern options, applied in order after the
+	// default options.
+	Overrides []Override `toml:"override"`
+}
+
+// Find walks up the directory tree starting at dir and returns the path of the
+// first configuration file it finds.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+
+	for {
+		for _, name := range []string{FileName, RCFileName} {
+			path := filepath.Join(dir, name)
+
+			info, err := os.Stat(path)
+			if err == nil && info.Mode().IsRegular() {
+				return path, nil
+			}
+
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return "", fmt.Errorf("%w", err)
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrNotFound
+		}
+
+		dir = parent
+	}
+}
+
+// Load reads and parses the configuration file at the given path. Both
+// imgdiet.toml and .imgdietrc files use the TOML format.
+func Load(path string) (*File, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	var file File
+
+	meta, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, undecoded[0])
+	}
+
+	for _, override := range file.Overrides {
+		if override.Pattern == "" {
+			return nil, fmt.Errorf("%w: empty pattern", ErrInvalidPattern)
+		}
+
+		if _, err = Match(override.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	file.dir = filepath.Dir(path)
+
+	if file.Output != "" && !filepath.IsAbs(file.Output) {
+		file.Output = filepath.Join(file.dir, file.Output)
+	}
+
+	return &file, nil
+}
+
+// Resolve applies the default options and every override matching the given
+// file path, in the order they appear in the configuration file, on top of
+// opts.
+func (f *File) Resolve(path string, opts *imgdiet.Options) (*imgdiet.Options, error) {
+	opts, err := f.Options.Apply(opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	name, ok := f.relative(path)
+	if !ok {
+		return opts, nil
+	}
+
+	for i := range f.Overrides {
+		matched, err := Match(f.Overrides[i].Pattern, name)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		if !matched {
+			continue
+		}
+
+		opts, err = f.Overrides[i].Apply(opts)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	return opts, nil
+}
+
+// Config returns the libvips configuration defined in the file, using
+// imgdiet.DefaultConfig for everything left unset.
+func (f *File) Config() *imgdiet.Config {
+	cfg := imgdiet.DefaultConfig()
+
+	if f.Library.Cache != nil {
+		cfg.Cache = *f.Library.Cache
+	}
+
+	if f.Library.Concurrency != nil {
+		cfg.MaxConcurrency = *f.Library.Concurrency
+	}
+
+	return cfg
+}
+
+// relative returns the slash-separated path of the given file relative to the
+// directory of the configuration file, and whether the file is inside it.
+func (f *File) relative(path string) (string, bool) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(f.dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return filepath.ToSlash(rel), true
+}
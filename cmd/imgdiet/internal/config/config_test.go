@@ -0,0 +1,306 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/config"
+)
+
+// writeFile writes the given content to the file at path, creating its parent
+// directories as needed.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("MkdirAll() failed: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		files []string
+		start string
+		want  string
+		err   error
+	}{
+		{
+			name:  "working_directory",
+			files: []string{"project/imgdiet.toml"},
+			start: "project",
+			want:  "project/imgdiet.toml",
+			err:   nil,
+		},
+		{
+			name:  "parent_directory",
+			files: []string{"project/imgdiet.toml"},
+			start: "project/assets/icons",
+			want:  "project/imgdiet.toml",
+			err:   nil,
+		},
+		{
+			name:  "rc_file",
+			files: []string{"project/.imgdietrc"},
+			start: "project/assets",
+			want:  "project/.imgdietrc",
+			err:   nil,
+		},
+		{
+			name:  "prefer_toml_over_rc_file",
+			files: []string{"project/.imgdietrc", "project/imgdiet.toml"},
+			start: "project",
+			want:  "project/imgdiet.toml",
+			err:   nil,
+		},
+		{
+			name:  "nearest_file",
+			files: []string{"project/imgdiet.toml", "project/assets/.imgdietrc"},
+			start: "project/assets/icons",
+			want:  "project/assets/.imgdietrc",
+			err:   nil,
+		},
+		{
+			name:  "directory_with_file_name",
+			files: []string{"project/imgdiet.toml/placeholder", "imgdiet.toml"},
+			start: "project",
+			want:  "imgdiet.toml",
+			err:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+
+			for _, file := range tt.files {
+				writeFile(t, filepath.Join(dir, file), "")
+			}
+
+			start := filepath.Join(dir, tt.start)
+
+			if err := os.MkdirAll(start, 0o755); err != nil {
+				t.Fatalf("MkdirAll() failed: %v", err)
+			}
+
+			got, err := config.Find(start)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if want := filepath.Join(dir, tt.want); got != want {
+				t.Errorf("Find() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		wantOut string
+		err     error
+	}{
+		{
+			name:    "relative_output",
+			give:    `output = "dist/images"`,
+			wantOut: "dist/images",
+			err:     nil,
+		},
+		{
+			name:    "parent_output",
+			give:    `output = "../public"`,
+			wantOut: "../public",
+			err:     nil,
+		},
+		{
+			name:    "no_output",
+			give:    `quality = 70`,
+			wantOut: "",
+			err:     nil,
+		},
+		{
+			name:    "unknown_key",
+			give:    `qualty = 70`,
+			wantOut: "",
+			err:     config.ErrUnknownKey,
+		},
+		{
+			name:    "empty_pattern",
+			give:    "[[override]]\nquality = 70",
+			wantOut: "",
+			err:     config.ErrInvalidPattern,
+		},
+		{
+			name:    "malformed_pattern",
+			give:    "[[override]]\npattern = \"icons/[\"\nquality = 70",
+			wantOut: "",
+			err:     config.ErrInvalidPattern,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				dir  = filepath.Join(t.TempDir(), "project")
+				path = filepath.Join(dir, config.FileName)
+			)
+
+			writeFile(t, path, tt.give)
+
+			got, err := config.Load(path)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			want := tt.wantOut
+			if want != "" {
+				want = filepath.Join(dir, want)
+			}
+
+			if got.Output != want {
+				t.Errorf("Load().Output = %q, want %q", got.Output, want)
+			}
+		})
+	}
+}
+
+func TestLoad_AbsoluteOutput(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dir    = t.TempDir()
+		path   = filepath.Join(dir, config.FileName)
+		output = filepath.Join(t.TempDir(), "images")
+	)
+
+	writeFile(t, path, "output = '"+output+"'")
+
+	got, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if got.Output != output {
+		t.Errorf("Load().Output = %q, want %q", got.Output, output)
+	}
+}
+
+func TestFile_Resolve(t *testing.T) {
+	t.Parallel()
+
+	const content = `
+quality = 80
+
+[[override]]
+pattern = "photos/**"
+quality = 70
+
+[[override]]
+pattern = "photos/thumbnails/*.jpg"
+quality = 50
+strip = true
+
+[[override]]
+pattern = "icons/**"
+preset = "lossless"
+`
+
+	lossless, presetErr := imgdiet.Preset(imgdiet.PresetLossless)
+	if presetErr != nil {
+		t.Fatalf("Preset() failed: %v", presetErr)
+	}
+
+	tests := []struct {
+		name      string
+		give      string
+		wantQ     uint
+		wantStrip bool
+	}{
+		{
+			name:      "default_options",
+			give:      "banner.jpg",
+			wantQ:     80,
+			wantStrip: false,
+		},
+		{
+			name:      "single_override",
+			give:      "photos/2023/beach.jpg",
+			wantQ:     70,
+			wantStrip: false,
+		},
+		{
+			name:      "later_override_wins",
+			give:      "photos/thumbnails/beach.jpg",
+			wantQ:     50,
+			wantStrip: true,
+		},
+		{
+			name:      "preset_override",
+			give:      "icons/logo.png",
+			wantQ:     lossless.Quality,
+			wantStrip: lossless.StripMetadata,
+		},
+		{
+			name:      "outside_configuration_directory",
+			give:      "../photos/beach.jpg",
+			wantQ:     80,
+			wantStrip: false,
+		},
+	}
+
+	dir := filepath.Join(t.TempDir(), "project")
+	path := filepath.Join(dir, config.FileName)
+
+	writeFile(t, path, content)
+
+	cfg, loadErr := config.Load(path)
+	if loadErr != nil {
+		t.Fatalf("Load() failed: %v", loadErr)
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := cfg.Resolve(filepath.Join(dir, tt.give), &imgdiet.Options{Quality: 60})
+			if err != nil {
+				t.Fatalf("Resolve() failed: %v", err)
+			}
+
+			if got.Quality != tt.wantQ {
+				t.Errorf("Resolve().Quality = %d, want %d", got.Quality, tt.wantQ)
+			}
+
+			if got.StripMetadata != tt.wantStrip {
+				t.Errorf("Resolve().StripMetadata = %v, want %v", got.StripMetadata, tt.wantStrip)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Match reports whether the slash-separated name matches the given glob
+// pattern. The pattern syntax is the same as path.Match, with the addition of
+// "**" path segments, which match zero or more directories.
+func Match(pattern, name string) (bool, error) {
+	var (
+		patterns = strings.Split(pattern, "/")
+		names    []string
+	)
+
+	for _, segment := range patterns {
+		if segment == "**" {
+			continue
+		}
+
+		if _, err := path.Match(segment, ""); err != nil {
+			return false, fmt.Errorf("%w: %s", ErrInvalidPattern, pattern)
+		}
+	}
+
+	if name != "" {
+		names = strings.Split(name, "/")
+	}
+
+	return match(patterns, names), nil
+}
+
+// match reports whether the name segments match the pattern segments. Both
+// are expected to be valid.
+func match(patterns, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if match(patterns[1:], names[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(names) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(patterns[0], names[0]); !ok { //nolint:errcheck // patterns are validated by Match
+			return false
+		}
+
+		patterns, names = patterns[1:], names[1:]
+	}
+
+	return len(names) == 0
+}
@@ -0,0 +1,101 @@
+package config_test
+
+import (
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/config"
+)
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		pattern string
+		give    string
+		want    bool
+		err     error
+	}{
+		{
+			name:    "exact_name",
+			pattern: "logo.png",
+			give:    "logo.png",
+			want:    true,
+			err:     nil,
+		},
+		{
+			name:    "star_does_not_cross_directories",
+			pattern: "*.png",
+			give:    "icons/logo.png",
+			want:    false,
+			err:     nil,
+		},
+		{
+			name:    "double_star_matches_zero_directories",
+			pattern: "icons/**/*.png",
+			give:    "icons/logo.png",
+			want:    true,
+			err:     nil,
+		},
+		{
+			name:    "double_star_matches_many_directories",
+			pattern: "icons/**/*.png",
+			give:    "icons/social/dark/logo.png",
+			want:    true,
+			err:     nil,
+		},
+		{
+			name:    "trailing_double_star",
+			pattern: "photos/**",
+			give:    "photos/2023/beach.jpg",
+			want:    true,
+			err:     nil,
+		},
+		{
+			name:    "leading_double_star",
+			pattern: "**/*.jpg",
+			give:    "photos/2023/beach.jpg",
+			want:    true,
+			err:     nil,
+		},
+		{
+			name:    "double_star_keeps_the_extension",
+			pattern: "icons/**/*.png",
+			give:    "icons/social/logo.svg",
+			want:    false,
+			err:     nil,
+		},
+		{
+			name:    "different_directory",
+			pattern: "photos/**",
+			give:    "icons/logo.png",
+			want:    false,
+			err:     nil,
+		},
+		{
+			name:    "malformed_pattern",
+			pattern: "icons/[",
+			give:    "icons/logo.png",
+			want:    false,
+			err:     config.ErrInvalidPattern,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := config.Match(tt.pattern, tt.give)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.give, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+// Package manifest implements a record of previously optimized images, used to
+// skip images whose input and settings have not changed since the last run.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+// FileName is the name of the manifest file stored in the output directory.
+const FileName string = ".imgdiet-manifest.json"
+
+// Entry describes how an output image was produced.
+type Entry struct {
+	// Input is the hash of the original image.
+	Input string `json:"input"`
+
+	// Options is the hash of the options used to optimize the image.
+	Options string `json:"options"`
+
+	// Version is the version of the application that optimized the image.
+	Version string `json:"version"`
+
+	// Output is the hash of the optimized image.
+	Output string `json:"output"`
+}
+
+// Manifest maps output images to the entries describing how they were
+// produced.
+type Manifest struct {
+	// Entries holds one entry per output image, keyed by its slash-separated
+	// path relative to the manifest directory.
+	Entries map[string]*Entry `json:"entries"`
+
+	// dir is the directory the manifest is stored in.
+	dir string
+
+	// changed defines whether the manifest was modified since it was loaded.
+	changed bool
+}
+
+// Load reads the manifest stored in the given directory. An empty manifest is
+// returned if the directory has none.
+func Load(dir string) (*Manifest, error) {
+	manifest := &Manifest{
+		Entries: make(map[string]*Entry),
+		dir:     dir,
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return manifest, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if manifest.Entries == nil {
+		manifest.Entries = make(map[string]*Entry)
+	}
+
+	return manifest, nil
+}
+
+// Fresh reports whether the image at output was produced from the same input,
+// options, and version described by entry, and has not been modified since.
+func (m *Manifest) Fresh(output string, entry *Entry) (bool, error) {
+	key, err := m.key(output)
+	if err != nil {
+		return false, err
+	}
+
+	previous, ok := m.Entries[key]
+	if !ok || previous.Input != entry.Input || previous.Options != entry.Options || previous.Version != entry.Version {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(output)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+
+	return Hash(data) == previous.Output, nil
+}
+
+// Set records the entry describing how the image at output was produced.
+func (m *Manifest) Set(output string, entry *Entry) error {
+	key, err := m.key(output)
+	if err != nil {
+		return err
+	}
+
+	m.Entries[key] = entry
+	m.changed = true
+
+	return nil
+}
+
+// Delete removes the entry for the image at path, or the entries for every
+// image inside it if path is a directory.
+func (m *Manifest) Delete(path string) error {
+	key, err := m.key(path)
+	if err != nil {
+		return err
+	}
+
+	for name := range m.Entries {
+		if name == key || strings.HasPrefix(name, key+"/") {
+			delete(m.Entries, name)
+
+			m.changed = true
+		}
+	}
+
+	return nil
+}
+
+// Save writes the manifest to its directory if it was modified since it was
+// loaded.
+func (m *Manifest) Save() error {
+	if !m.changed {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	// Write to a temporary file first so an interrupted run never leaves a
+	// truncated manifest behind.
+	file, err := os.CreateTemp(m.dir, FileName+".*")
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = os.Rename(file.Name(), filepath.Join(m.dir, FileName)); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	m.changed = false
+
+	return nil
+}
+
+// key returns the key used to store the entry for the image at output.
+func (m *Manifest) key(output string) (string, error) {
+	rel, err := filepath.Rel(m.dir, output)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// Hash returns the hex-encoded SHA-256 hash of the given data.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// HashOptions returns the hex-encoded SHA-256 hash of the given Options.
+func HashOptions(opts *imgdiet.Options) (string, error) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return "", fmt.Errorf("%w", err)
+	}
+
+	return Hash(data), nil
+}
@@ -0,0 +1,395 @@
+package manifest_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/manifest"
+)
+
+// testEntry returns the entry of an image optimized into the given output
+// data.
+func testEntry(output []byte) *manifest.Entry {
+	return &manifest.Entry{
+		Input:   manifest.Hash([]byte("input")),
+		Options: manifest.Hash([]byte("options")),
+		Version: "1.0.0",
+		Output:  manifest.Hash(output),
+	}
+}
+
+func TestManifest_Fresh(t *testing.T) {
+	t.Parallel()
+
+	output := []byte("output")
+
+	tests := []struct {
+		name   string
+		modify func(entry *manifest.Entry)
+		write  []byte
+		want   bool
+	}{
+		{
+			name:   "unchanged",
+			modify: func(_ *manifest.Entry) {},
+			write:  output,
+			want:   true,
+		},
+		{
+			name: "input_changed",
+			modify: func(entry *manifest.Entry) {
+				entry.Input = manifest.Hash([]byte("new input"))
+			},
+			write: output,
+			want:  false,
+		},
+		{
+			name: "options_changed",
+			modify: func(entry *manifest.Entry) {
+				entry.Options = manifest.Hash([]byte("new options"))
+			},
+			write: output,
+			want:  false,
+		},
+		{
+			name: "version_changed",
+			modify: func(entry *manifest.Entry) {
+				entry.Version = "1.1.0"
+			},
+			write: output,
+			want:  false,
+		},
+		{
+			name:   "output_modified",
+			modify: func(_ *manifest.Entry) {},
+			write:  []byte("edited output"),
+			want:   false,
+		},
+		{
+			name:   "output_missing",
+			modify: func(_ *manifest.Entry) {},
+			write:  nil,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				dir  = t.TempDir()
+				path = filepath.Join(dir, "images", "photo.jpg")
+			)
+
+			m, err := manifest.Load(dir)
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+
+			if err = m.Set(path, testEntry(output)); err != nil {
+				t.Fatalf("Set() failed: %v", err)
+			}
+
+			if tt.write != nil {
+				if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatalf("MkdirAll() failed: %v", err)
+				}
+
+				if err = os.WriteFile(path, tt.write, 0o600); err != nil {
+					t.Fatalf("WriteFile() failed: %v", err)
+				}
+			}
+
+			entry := testEntry(output)
+			tt.modify(entry)
+
+			got, err := m.Fresh(path, entry)
+			if err != nil {
+				t.Fatalf("Fresh() failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Fresh() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifest_Fresh_UnknownOutput(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	got, err := m.Fresh(filepath.Join(dir, "photo.jpg"), testEntry(nil))
+	if err != nil {
+		t.Fatalf("Fresh() failed: %v", err)
+	}
+
+	if got {
+		t.Error("Fresh() = true for an image missing from the manifest")
+	}
+}
+
+func TestManifest_Delete(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		want []string
+	}{
+		{
+			name: "file",
+			give: "photos/beach.jpg",
+			want: []string{"photos-2023/beach.jpg", "photos/city.jpg", "photos/thumbnails/beach.jpg"},
+		},
+		{
+			name: "directory",
+			give: "photos",
+			want: []string{"photos-2023/beach.jpg"},
+		},
+		{
+			name: "unknown_path",
+			give: "icons/logo.png",
+			want: []string{"photos-2023/beach.jpg", "photos/beach.jpg", "photos/city.jpg", "photos/thumbnails/beach.jpg"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+
+			m, err := manifest.Load(dir)
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+
+			for _, name := range []string{"photos/beach.jpg", "photos/city.jpg", "photos/thumbnails/beach.jpg", "photos-2023/beach.jpg"} {
+				if err = m.Set(filepath.Join(dir, name), testEntry(nil)); err != nil {
+					t.Fatalf("Set() failed: %v", err)
+				}
+			}
+
+			if err = m.Delete(filepath.Join(dir, tt.give)); err != nil {
+				t.Fatalf("Delete() failed: %v", err)
+			}
+
+			got := make([]string, 0, len(m.Entries))
+
+			for name := range m.Entries {
+				got = append(got, name)
+			}
+
+			if !reflect.DeepEqual(sorted(got), tt.want) {
+				t.Errorf("Entries = %v, want %v", sorted(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestManifest_Save(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dir  = filepath.Join(t.TempDir(), "dist")
+		path = filepath.Join(dir, "images", "photo.jpg")
+	)
+
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	// An unmodified manifest is not written.
+	if err = m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, manifest.FileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no manifest file, got %v", err)
+	}
+
+	entry := testEntry([]byte("output"))
+
+	if err = m.Set(path, entry); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	if err = m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() failed: %v", err)
+	}
+
+	// The temporary file is renamed over the manifest, so nothing else is
+	// left behind.
+	if len(files) != 1 || files[0].Name() != manifest.FileName {
+		t.Errorf("expected only %s in the output directory, got %v", manifest.FileName, files)
+	}
+
+	loaded, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	want := map[string]*manifest.Entry{
+		"images/photo.jpg": entry,
+	}
+
+	if !reflect.DeepEqual(loaded.Entries, want) {
+		t.Errorf("Entries = %v, want %v", loaded.Entries, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:    "missing_file",
+			give:    "",
+			want:    0,
+			wantErr: false,
+		},
+		{
+			name:    "empty_object",
+			give:    "{}",
+			want:    0,
+			wantErr: false,
+		},
+		{
+			name:    "single_entry",
+			give:    `{"entries": {"photo.jpg": {"input": "a", "options": "b", "version": "c", "output": "d"}}}`,
+			want:    1,
+			wantErr: false,
+		},
+		{
+			name:    "corrupt_file",
+			give:    `{"entries": {"photo.jpg": `,
+			want:    0,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+
+			if tt.give != "" {
+				if err := os.WriteFile(filepath.Join(dir, manifest.FileName), []byte(tt.give), 0o600); err != nil {
+					t.Fatalf("WriteFile() failed: %v", err)
+				}
+			}
+
+			got, err := manifest.Load(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if len(got.Entries) != tt.want {
+				t.Errorf("len(Entries) = %d, want %d", len(got.Entries), tt.want)
+			}
+		})
+	}
+}
+
+func TestHashOptions(t *testing.T) {
+	t.Parallel()
+
+	base, baseErr := manifest.HashOptions(imgdiet.DefaultOptions())
+	if baseErr != nil {
+		t.Fatalf("HashOptions() failed: %v", baseErr)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(opts *imgdiet.Options)
+		same   bool
+	}{
+		{
+			name:   "same_options",
+			modify: func(_ *imgdiet.Options) {},
+			same:   true,
+		},
+		{
+			name: "different_quality",
+			modify: func(opts *imgdiet.Options) {
+				opts.Quality = 90
+			},
+			same: false,
+		},
+		{
+			name: "metadata_policy",
+			modify: func(opts *imgdiet.Options) {
+				opts.Metadata = imgdiet.PrivacyMetadataPolicy()
+			},
+			same: false,
+		},
+		{
+			name: "background",
+			modify: func(opts *imgdiet.Options) {
+				opts.Background = &imgdiet.Color{R: 0, G: 0, B: 0}
+			},
+			same: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := imgdiet.DefaultOptions()
+			tt.modify(opts)
+
+			got, err := manifest.HashOptions(opts)
+			if err != nil {
+				t.Fatalf("HashOptions() failed: %v", err)
+			}
+
+			if (got == base) != tt.same {
+				t.Errorf("HashOptions() = %s, base %s, want same %v", got, base, tt.same)
+			}
+		})
+	}
+}
+
+// sorted returns the given names in alphabetical order.
+func sorted(names []string) []string {
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,194 @@
+// Package report implements machine-readable summaries of the images processed
+// by the application.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// List of supported report formats.
+const (
+	FormatJSON string = "json"
+	FormatText string = "text"
+)
+
+// ErrUnknownFormat is returned when the requested report format is not
+// supported.
+const ErrUnknownFormat xerrors.Error = "unknown report format"
+
+// Record holds the outcome of processing a single image.
+type Record struct {
+	// Input is the path of the original image.
+	Input string `json:"input"`
+
+	// Output is the path the optimized image is written to.
+	Output string `json:"output"`
+
+	// Format is the type of the original image.
+	Format string `json:"format,omitempty"`
+
+	// Error is the reason processing the image failed, if it did.
+	Error string `json:"error,omitempty"`
+
+	// Width is the width of the image in pixels.
+	Width int `json:"width,omitempty"`
+
+	// Height is the height of the image in pixels.
+	Height int `json:"height,omitempty"`
+
+	// Size is the size of the original image in bytes.
+	Size int64 `json:"size"`
+
+	// Saved is the size of the optimized image in bytes.
+	Saved int64 `json:"saved"`
+
+	// Duration is the time spent processing the image in milliseconds.
+	Duration int64 `json:"duration_ms"`
+
+	// Savings is the size reduction achieved, as a percentage of Size.
+	Savings float64 `json:"savings_percent"`
+
+	// Skipped defines whether the image was skipped because its output was
+	// already up to date.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// String returns a human-readable, single-line summary of the record.
+func (r *Record) String() string {
+	switch {
+	case r.Error != "":
+		return fmt.Sprintf("%s: failed: %s", r.Input, r.Error)
+	case r.Skipped:
+		return fmt.Sprintf("%s: skipped, output is up to date", r.Input)
+	default:
+		return fmt.Sprintf("%s: %d -> %d bytes (%.2f%% saved)", r.Input, r.Size, r.Saved, r.Savings)
+	}
+}
+
+// Totals holds the aggregated outcome of processing every image.
+type Totals struct {
+	// Files is the number of images processed.
+	Files int `json:"files"`
+
+	// Failed is the number of images that failed to process.
+	Failed int `json:"failed"`
+
+	// Skipped is the number of images skipped because their output was already
+	// up to date.
+	Skipped int `json:"skipped"`
+
+	// Size is the size of all successfully processed original images in bytes.
+	Size int64 `json:"size"`
+
+	// Saved is the size of all successfully optimized images in bytes.
+	Saved int64 `json:"saved"`
+
+	// Duration is the time spent processing all images in milliseconds.
+	Duration int64 `json:"duration_ms"`
+
+	// Savings is the size reduction achieved, as a percentage of Size.
+	Savings float64 `json:"savings_percent"`
+}
+
+// Report holds the records of every image processed in a run.
+type Report struct {
+	// format is the format the report is written in.
+	format string
+
+	// Files holds one record per processed image, in processing order.
+	Files []*Record `json:"files"`
+
+	// Totals holds the aggregated outcome of all records.
+	Totals Totals `json:"totals"`
+}
+
+// New returns an empty Report to be written in the given format.
+func New(format string) (*Report, error) {
+	if format != FormatJSON && format != FormatText {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+
+	return &Report{
+		Files:  make([]*Record, 0),
+		format: format,
+	}, nil
+}
+
+// Add adds the given record to the report, computing its savings and updating
+// the report totals.
+func (r *Report) Add(record *Record) {
+	r.Totals.Files++
+	r.Totals.Duration += record.Duration
+
+	if record.Skipped {
+		r.Totals.Skipped++
+	}
+
+	if record.Error != "" {
+		r.Totals.Failed++
+	} else {
+		record.Savings = Savings(record.Size, record.Saved)
+
+		r.Totals.Size += record.Size
+		r.Totals.Saved += record.Saved
+		r.Totals.Savings = Savings(r.Totals.Size, r.Totals.Saved)
+	}
+
+	r.Files = append(r.Files, record)
+}
+
+// Write writes the report to the given writer in the format given to New.
+func (r *Report) Write(w io.Writer) error {
+	if r.format == FormatText {
+		return r.writeText(w)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// writeText writes the report to the given writer as human-readable text, one
+// line per record followed by the totals.
+func (r *Report) writeText(w io.Writer) error {
+	for _, record := range r.Files {
+		if _, err := fmt.Fprintln(w, record); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	_, err := fmt.Fprintf(
+		w,
+		"total: %d files, %d failed, %d skipped, %d -> %d bytes (%.2f%% saved)\n",
+		r.Totals.Files,
+		r.Totals.Failed,
+		r.Totals.Skipped,
+		r.Totals.Size,
+		r.Totals.Saved,
+		r.Totals.Savings,
+	)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Savings returns the size reduction from size to saved as a percentage of
+// size. It is negative when saved is larger than size.
+func Savings(size, saved int64) float64 {
+	if size == 0 {
+		return 0
+	}
+
+	return float64(size-saved) / float64(size) * 100
+}
@@ -0,0 +1,301 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go/cmd/imgdiet/internal/report"
+)
+
+// testRecords returns a set of records covering an optimized, a skipped, and
+// a failed image.
+func testRecords() []*report.Record {
+	return []*report.Record{
+		{
+			Input:    "images/photo.jpg",
+			Output:   "dist/photo.jpg",
+			Format:   "jpeg",
+			Width:    1920,
+			Height:   1080,
+			Size:     1000,
+			Saved:    600,
+			Duration: 120,
+		},
+		{
+			Input:    "images/logo.png",
+			Output:   "dist/logo.png",
+			Size:     500,
+			Saved:    400,
+			Duration: 5,
+			Skipped:  true,
+		},
+		{
+			Input:    "images/broken.gif",
+			Output:   "dist/broken.gif",
+			Error:    "failed to open image",
+			Size:     300,
+			Duration: 1,
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		err  error
+	}{
+		{
+			name: "JSON",
+			give: report.FormatJSON,
+			err:  nil,
+		},
+		{
+			name: "text",
+			give: report.FormatText,
+			err:  nil,
+		},
+		{
+			name: "unknown_format",
+			give: "yaml",
+			err:  report.ErrUnknownFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := report.New(tt.give)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err == nil && len(got.Files) != 0 {
+				t.Errorf("expected empty report, got %d records", len(got.Files))
+			}
+		})
+	}
+}
+
+func TestReport_Add(t *testing.T) {
+	t.Parallel()
+
+	r, err := report.New(report.FormatJSON)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	records := testRecords()
+
+	for _, record := range records {
+		r.Add(record)
+	}
+
+	want := report.Totals{
+		Files:    3,
+		Failed:   1,
+		Skipped:  1,
+		Size:     1500,
+		Saved:    1000,
+		Duration: 126,
+		Savings:  report.Savings(1500, 1000),
+	}
+
+	if r.Totals != want {
+		t.Errorf("Totals = %+v, want %+v", r.Totals, want)
+	}
+
+	if !reflect.DeepEqual(r.Files, records) {
+		t.Fatalf("Files = %v, want %v", r.Files, records)
+	}
+
+	savings := []float64{40, 20, 0}
+
+	for i, record := range r.Files {
+		if record.Savings != savings[i] {
+			t.Errorf("Files[%d].Savings = %v, want %v", i, record.Savings, savings[i])
+		}
+	}
+}
+
+func TestReport_Write(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		format string
+		give   []*report.Record
+		want   string
+	}{
+		{
+			name:   "text",
+			format: report.FormatText,
+			give:   testRecords(),
+			want: "images/photo.jpg: 1000 -> 600 bytes (40.00% saved)\n" +
+				"images/logo.png: skipped, output is up to date\n" +
+				"images/broken.gif: failed: failed to open image\n" +
+				"total: 3 files, 1 failed, 1 skipped, 1500 -> 1000 bytes (33.33% saved)\n",
+		},
+		{
+			name:   "empty_text",
+			format: report.FormatText,
+			give:   nil,
+			want:   "total: 0 files, 0 failed, 0 skipped, 0 -> 0 bytes (0.00% saved)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := report.New(tt.format)
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+
+			for _, record := range tt.give {
+				r.Add(record)
+			}
+
+			var buffer bytes.Buffer
+
+			if err = r.Write(&buffer); err != nil {
+				t.Fatalf("Write() failed: %v", err)
+			}
+
+			if got := buffer.String(); got != tt.want {
+				t.Errorf("Write() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReport_Write_JSON(t *testing.T) {
+	t.Parallel()
+
+	r, err := report.New(report.FormatJSON)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	for _, record := range testRecords() {
+		r.Add(record)
+	}
+
+	var buffer bytes.Buffer
+
+	if err = r.Write(&buffer); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	var got map[string]any
+
+	if err = json.Unmarshal(buffer.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+
+	want := map[string]any{
+		"files": []any{
+			map[string]any{
+				"input":           "images/photo.jpg",
+				"output":          "dist/photo.jpg",
+				"format":          "jpeg",
+				"width":           1920.0,
+				"height":          1080.0,
+				"size":            1000.0,
+				"saved":           600.0,
+				"duration_ms":     120.0,
+				"savings_percent": 40.0,
+			},
+			map[string]any{
+				"input":           "images/logo.png",
+				"output":          "dist/logo.png",
+				"size":            500.0,
+				"saved":           400.0,
+				"duration_ms":     5.0,
+				"savings_percent": 20.0,
+				"skipped":         true,
+			},
+			map[string]any{
+				"input":           "images/broken.gif",
+				"output":          "dist/broken.gif",
+				"error":           "failed to open image",
+				"size":            300.0,
+				"saved":           0.0,
+				"duration_ms":     1.0,
+				"savings_percent": 0.0,
+			},
+		},
+		"totals": map[string]any{
+			"files":           3.0,
+			"failed":          1.0,
+			"skipped":         1.0,
+			"size":            1500.0,
+			"saved":           1000.0,
+			"duration_ms":     126.0,
+			"savings_percent": report.Savings(1500, 1000),
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Write() = %v, want %v", got, want)
+	}
+}
+
+func TestSavings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		size  int64
+		saved int64
+		want  float64
+	}{
+		{
+			name:  "smaller_output",
+			size:  200,
+			saved: 50,
+			want:  75,
+		},
+		{
+			name:  "same_size",
+			size:  200,
+			saved: 200,
+			want:  0,
+		},
+		{
+			name:  "larger_output",
+			size:  200,
+			saved: 300,
+			want:  -50,
+		},
+		{
+			name:  "empty_input",
+			size:  0,
+			saved: 100,
+			want:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := report.Savings(tt.size, tt.saved); got != tt.want {
+				t.Errorf("Savings(%d, %d) = %v, want %v", tt.size, tt.saved, got, tt.want)
+			}
+		})
+	}
+}
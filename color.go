@@ -0,0 +1,171 @@
+package imgdiet
+
+import (
+	"fmt"
+	"os"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// ErrColorProfileNotFound is returned when the ICC profile to convert an image
+// to does not exist.
+const ErrColorProfileNotFound xerrors.Error = "color profile not found"
+
+// ColorProfileSRGB is the name of the built-in sRGB IEC61966-2.1 profile, the
+// color space browsers assume for images without an ICC profile.
+const ColorProfileSRGB string = "srgb"
+
+// Intent defines how colors outside of the gamut of the ICC profile an image
+// is converted to are brought into it.
+type Intent int
+
+// List of rendering intents.
+const (
+	// IntentPerceptual compresses every color of the image into the
+	// destination gamut, keeping the relationships between them. It is the
+	// default, and suits photographs.
+	IntentPerceptual Intent = iota
+
+	// IntentRelativeColorimetric keeps the colors inside the destination gamut
+	// unchanged, adapted to its white point, and clips the ones outside of it
+	// to the closest color available. It suits images whose colors mostly fit
+	// the destination gamut, such as logos.
+	IntentRelativeColorimetric
+
+	// IntentSaturation favors vivid colors over accurate ones, and suits
+	// charts and illustrations.
+	IntentSaturation
+
+	// IntentAbsoluteColorimetric works like IntentRelativeColorimetric without
+	// adapting the colors to the destination white point, and suits proofs
+	// simulating another medium.
+	IntentAbsoluteColorimetric
+)
+
+// vips returns the libvips rendering intent matching the Intent.
+func (i Intent) vips() vips.Intent {
+	switch i {
+	case IntentRelativeColorimetric:
+		return vips.IntentRelative
+	case IntentSaturation:
+		return vips.IntentSaturation
+	case IntentAbsoluteColorimetric:
+		return vips.IntentAbsolute
+	case IntentPerceptual:
+		fallthrough
+	default:
+		return vips.IntentPerceptual
+	}
+}
+
+// Color is an RGB color.
+type Color struct {
+	R, G, B uint8
+}
+
+// ConvertColorProfile converts the image pixels from their embedded ICC
+// profile to the given one with the given rendering intent, and embeds it in
+// the image. The profile is either ColorProfileSRGB or the path of an ICC
+// profile file, such as a Display P3 profile.
+//
+// Images without an embedded profile are assumed to be in sRGB, except for
+// CMYK images, which are converted using a generic CMYK profile. Grayscale
+// images without an embedded profile are left untouched.
+//
+// Profiles that only describe their gamut with a matrix, such as sRGB and
+// Display P3, convert colors the same way with the perceptual and relative
+// colorimetric intents, as they have no perceptual mapping of their own.
+func (i *Image) ConvertColorProfile(profile string, intent Intent) error {
+	path, err := colorProfilePath(profile)
+	if err != nil {
+		return err
+	}
+
+	i.modified()
+
+	embedded := i.reference.HasICCProfile()
+
+	if i.reference.Interpretation() == vips.InterpretationCMYK && !embedded {
+		if err = i.reference.ToColorSpace(vips.InterpretationSRGB); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		// The pixels are now in sRGB, so there is nothing left to do unless
+		// another profile was requested.
+		if profile == ColorProfileSRGB {
+			return nil
+		}
+	}
+
+	if !embedded && i.reference.Bands() <= 2 {
+		return nil
+	}
+
+	return iccTransform(i.reference, path, intent.vips())
+}
+
+// colorProfilePath returns the path of the given ICC profile.
+func colorProfilePath(profile string) (string, error) {
+	if profile == ColorProfileSRGB {
+		return vips.SRGBIEC6196621ICCProfilePath, nil
+	}
+
+	if _, err := os.Stat(profile); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrColorProfileNotFound, err)
+	}
+
+	return profile, nil
+}
+
+// WideGamut reports whether the embedded ICC profile of the image describes a
+// color space noticeably wider than sRGB, such as Display P3, Adobe RGB, or
+// ProPhoto RGB.
+func (i *Image) WideGamut() bool {
+	if i.reference.Bands() < 3 || !i.reference.HasICCProfile() {
+		return false
+	}
+
+	return iccWideGamut(i.reference.GetICCProfile())
+}
+
+// manageColor applies the color management steps required by the given
+// Options. It reports whether the resulting ICC profile must be kept even when
+// stripping metadata, as the image would otherwise be displayed with the wrong
+// colors.
+func (i *Image) manageColor(opts *Options) (bool, error) {
+	format := i.outputFormat(opts)
+
+	// The WebP encoder replaces the ICC profile of the image with an sRGB one,
+	// so the colors must be converted to sRGB for them to stay the same.
+	if format == ImageTypeWebP {
+		if err := i.reference.OptimizeICCProfile(); err != nil {
+			return false, fmt.Errorf("%w", err)
+		}
+
+		return false, nil
+	}
+
+	if opts.ColorProfile != "" {
+		if err := i.ConvertColorProfile(opts.ColorProfile, opts.RenderingIntent); err != nil {
+			return false, err
+		}
+	}
+
+	var (
+		custom = opts.ColorProfile != "" && opts.ColorProfile != ColorProfileSRGB
+		wide   = opts.PreserveWideGamut && format != ImageTypeGIF && i.WideGamut()
+	)
+
+	if custom || wide {
+		return true, nil
+	}
+
+	if opts.OptimizeICCProfile {
+		if err := i.reference.OptimizeICCProfile(); err != nil {
+			return false, fmt.Errorf("%w", err)
+		}
+	}
+
+	return false, nil
+}
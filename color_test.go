@@ -0,0 +1,327 @@
+package imgdiet_test
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"os"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+func TestImage_ConvertColorProfile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		profile string
+		wantICC bool
+		err     error
+	}{
+		{
+			name:    "valid_JPEG_image_to_sRGB",
+			give:    _TestDataPath + "/" + _TestValidImageJPG,
+			profile: imgdiet.ColorProfileSRGB,
+			wantICC: true,
+			err:     nil,
+		},
+		{
+			name:    "valid_PNG_image_to_sRGB",
+			give:    _TestDataPath + "/" + _TestValidImagePNG,
+			profile: imgdiet.ColorProfileSRGB,
+			wantICC: true,
+			err:     nil,
+		},
+		{
+			name:    "valid_JPEG_image_to_Display_P3",
+			give:    _TestDataPath + "/" + _TestValidImageJPG,
+			profile: _TestDataPath + "/" + _TestWideGamutProfile,
+			wantICC: true,
+			err:     nil,
+		},
+		{
+			name:    "non-existent_profile",
+			give:    _TestDataPath + "/" + _TestValidImageJPG,
+			profile: _TestDataPath + "/impossible-girl.icc",
+			wantICC: true,
+			err:     imgdiet.ErrColorProfileNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(tt.give)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			image, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer image.Close()
+
+			err = image.ConvertColorProfile(tt.profile, imgdiet.IntentPerceptual)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got := image.Metadata().ICCProfile != nil; got != tt.wantICC {
+				t.Errorf("expected ICC profile %t, got %t", tt.wantICC, got)
+			}
+		})
+	}
+}
+
+func TestImage_ConvertColorProfile_Intent(t *testing.T) {
+	t.Parallel()
+
+	// The test profile maps colors to gray, halving their lightness with the
+	// perceptual intent only.
+	convert := func(t *testing.T, intent imgdiet.Intent) float64 {
+		t.Helper()
+
+		image := openTestImage(t, _TestDataPath+"/"+_TestValidImageJPG)
+
+		if err := image.ConvertColorProfile(_TestDataPath+"/"+_TestIntentsProfile, intent); err != nil {
+			t.Fatalf("ConvertColorProfile() failed: %v", err)
+		}
+
+		data, err := image.Optimize(&imgdiet.Options{Format: imgdiet.ImageTypePNG})
+		if err != nil {
+			t.Fatalf("Optimize() failed: %v", err)
+		}
+
+		converted, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("png.Decode() failed: %v", err)
+		}
+
+		var (
+			bounds = converted.Bounds()
+			sum    float64
+		)
+
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, _, _, _ := converted.At(x, y).RGBA()
+				sum += float64(r >> 8)
+			}
+		}
+
+		return sum / float64(bounds.Dx()*bounds.Dy())
+	}
+
+	var (
+		perceptual = convert(t, imgdiet.IntentPerceptual)
+		relative   = convert(t, imgdiet.IntentRelativeColorimetric)
+	)
+
+	if relative == 0 || perceptual > relative*0.75 {
+		t.Errorf("expected perceptual mean %.2f to be about half the relative colorimetric mean %.2f", perceptual, relative)
+	}
+}
+
+func TestImage_Optimize_ColorProfile(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.Open(_TestDataPath + "/" + _TestValidImageJPG)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	image, err := imgdiet.Open(file)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer image.Close()
+
+	opts := imgdiet.DefaultOptions()
+	opts.ColorProfile = imgdiet.ColorProfileSRGB
+
+	if _, err = image.Optimize(opts); err != nil {
+		t.Fatalf("Optimize() failed: %v", err)
+	}
+}
+
+func TestImage_WideGamut(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		want bool
+	}{
+		{
+			name: "sRGB_JPEG_image",
+			give: _TestDataPath + "/" + _TestValidImageJPG,
+			want: false,
+		},
+		{
+			name: "Display_P3_JPEG_image",
+			give: _TestDataPath + "/" + _TestWideGamutImageJPG,
+			want: true,
+		},
+		{
+			name: "PNG_image_without_profile",
+			give: _TestDataPath + "/" + _TestValidImagePNG,
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(tt.give)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			image, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer image.Close()
+
+			if got := image.WideGamut(); got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestImage_Optimize_PreserveWideGamut(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		preserve bool
+		want     bool
+	}{
+		{
+			name:     "preserve",
+			preserve: true,
+			want:     true,
+		},
+		{
+			name:     "convert_to_sRGB",
+			preserve: false,
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(_TestDataPath + "/" + _TestWideGamutImageJPG)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			image, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer image.Close()
+
+			opts := imgdiet.DefaultOptions()
+			opts.PreserveWideGamut = tt.preserve
+
+			got, err := image.Optimize(opts)
+			if err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+
+			optimized, err := imgdiet.Open(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("Open() failed on optimized image: %v", err)
+			}
+			defer optimized.Close()
+
+			if optimized.WideGamut() != tt.want {
+				t.Errorf("expected wide gamut %t, got %t", tt.want, optimized.WideGamut())
+			}
+		})
+	}
+}
+
+func TestImage_Optimize_PreserveDepth(t *testing.T) {
+	t.Parallel()
+
+	// The bit depth of a PNG image is stored right after the width and height
+	// in its IHDR chunk.
+	const bitDepthOffset = 24
+
+	tests := []struct {
+		name     string
+		preserve bool
+		want     byte
+	}{
+		{
+			name:     "preserve",
+			preserve: true,
+			want:     16,
+		},
+		{
+			name:     "reduce",
+			preserve: false,
+			want:     8,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(_TestDataPath + "/" + _TestHighDepthImagePNG)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			image, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer image.Close()
+
+			opts := imgdiet.DefaultOptions()
+			opts.PreserveDepth = tt.preserve
+
+			got, err := image.Optimize(opts)
+			if err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+
+			if len(got) <= bitDepthOffset {
+				t.Fatalf("expected a PNG image, got %d bytes", len(got))
+			}
+
+			if got[bitDepthOffset] != tt.want {
+				t.Errorf("expected bit depth %d, got %d", tt.want, got[bitDepthOffset])
+			}
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package imgdiet
+
+import (
+	"fmt"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+const (
+	// ErrInvalidSigma is returned when the sigma of a blur or sharpen is not
+	// positive.
+	ErrInvalidSigma xerrors.Error = "sigma must be greater than 0"
+
+	// ErrInvalidSharpen is returned when the threshold or amount of a sharpen
+	// is negative.
+	ErrInvalidSharpen xerrors.Error = "sharpen threshold and amount must not be negative"
+
+	// ErrInvalidAdjustment is returned when an adjustment factor is negative, or
+	// when gamma is not positive.
+	ErrInvalidAdjustment xerrors.Error = "invalid adjustment"
+
+	// ErrUnsupportedBandFormat is returned when an operation does not support
+	// the pixel format of the image, such as floating-point pixels.
+	ErrUnsupportedBandFormat xerrors.Error = "unsupported band format"
+)
+
+// Sepia tone weights applied to the luminance of each pixel to get its red,
+// green, and blue values.
+const (
+	sepiaRed   float64 = 1.351
+	sepiaGreen float64 = 1.203
+	sepiaBlue  float64 = 0.937
+)
+
+// SharpenOptions represents the parameters used to sharpen an image.
+type SharpenOptions struct {
+	// Sigma is the radius of the Gaussian used to find edges, in pixels.
+	// Larger values sharpen coarser details.
+	Sigma float64
+
+	// Threshold is the level below which details are considered flat areas,
+	// such as noise, and are left mostly untouched.
+	Threshold float64
+
+	// Amount is how strongly details above Threshold are sharpened.
+	Amount float64
+}
+
+// DefaultSharpenOptions returns a set of defaults for recovering the details
+// lost when downscaling an image.
+func DefaultSharpenOptions() *SharpenOptions {
+	return &SharpenOptions{
+		Sigma:     0.5,
+		Threshold: 2,
+		Amount:    3,
+	}
+}
+
+// Validate returns an error if the SharpenOptions are out of range.
+func (o *SharpenOptions) Validate() error {
+	if o.Sigma <= 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidSigma, o.Sigma)
+	}
+
+	if o.Threshold < 0 || o.Amount < 0 {
+		return fmt.Errorf("%w: threshold %v, amount %v", ErrInvalidSharpen, o.Threshold, o.Amount)
+	}
+
+	return nil
+}
+
+// Adjustments represents the tonal and color adjustments applied to an image.
+// Each factor is 1 to leave the image untouched.
+type Adjustments struct {
+	// Brightness multiplies the lightness of the image, such as 1.2 to make it
+	// 20% brighter. It must not be negative.
+	Brightness float64
+
+	// Contrast multiplies the distance of each pixel from middle gray, such as
+	// 0.8 to make the image flatter. It must not be negative.
+	Contrast float64
+
+	// Saturation multiplies the chroma of the image, where 0 removes all
+	// color. It must not be negative.
+	Saturation float64
+
+	// Gamma is the gamma correction applied to the image, where values greater
+	// than 1 lighten the midtones and values less than 1 darken them. It must
+	// be greater than 0.
+	Gamma float64
+}
+
+// DefaultAdjustments returns a set of Adjustments that leave the image
+// untouched.
+func DefaultAdjustments() *Adjustments {
+	return &Adjustments{
+		Brightness: 1,
+		Contrast:   1,
+		Saturation: 1,
+		Gamma:      1,
+	}
+}
+
+// Validate returns an error if the Adjustments are out of range.
+func (a *Adjustments) Validate() error {
+	if a.Brightness < 0 {
+		return fmt.Errorf("%w: brightness %v", ErrInvalidAdjustment, a.Brightness)
+	}
+
+	if a.Contrast < 0 {
+		return fmt.Errorf("%w: contrast %v", ErrInvalidAdjustment, a.Contrast)
+	}
+
+	if a.Saturation < 0 {
+		return fmt.Errorf("%w: saturation %v", ErrInvalidAdjustment, a.Saturation)
+	}
+
+	if a.Gamma <= 0 {
+		return fmt.Errorf("%w: gamma %v", ErrInvalidAdjustment, a.Gamma)
+	}
+
+	return nil
+}
+
+// Sharpen sharpens the image according to the given SharpenOptions, or
+// DefaultSharpenOptions if nil.
+func (i *Image) Sharpen(opts *SharpenOptions) error {
+	if opts == nil {
+		opts = DefaultSharpenOptions()
+	}
+
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	i.modified()
+
+	if err := i.reference.Sharpen(opts.Sigma, opts.Threshold, opts.Amount); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Blur applies a Gaussian blur of the given sigma, in pixels, to the image.
+// Large values, such as 20, make faces and text unrecognizable.
+func (i *Image) Blur(sigma float64) error {
+	if sigma <= 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidSigma, sigma)
+	}
+
+	i.modified()
+
+	if err := i.reference.GaussianBlur(sigma); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Adjust applies the given Adjustments to the image. Brightness and saturation
+// are applied first, followed by contrast and gamma.
+func (i *Image) Adjust(adjustments *Adjustments) error {
+	if adjustments == nil {
+		return nil
+	}
+
+	if err := adjustments.Validate(); err != nil {
+		return err
+	}
+
+	i.modified()
+
+	if adjustments.Brightness != 1 || adjustments.Saturation != 1 {
+		if err := i.reference.Modulate(adjustments.Brightness, adjustments.Saturation, 0); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if adjustments.Contrast != 1 {
+		if err := i.contrast(adjustments.Contrast); err != nil {
+			return err
+		}
+	}
+
+	if adjustments.Gamma != 1 {
+		if err := i.gamma(adjustments.Gamma); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Grayscale converts the image to grayscale, keeping its alpha channel.
+//
+// The ICC profile of the image is removed, as it describes the original
+// colors.
+func (i *Image) Grayscale() error {
+	i.modified()
+
+	if err := i.reference.ToColorSpace(vips.InterpretationBW); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := i.reference.RemoveICCProfile(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Sepia converts the image to sepia tones, keeping its alpha channel.
+//
+// The ICC profile of the image is removed, as it describes the original
+// colors.
+func (i *Image) Sepia() error {
+	if err := i.Grayscale(); err != nil {
+		return err
+	}
+
+	if err := i.reference.ToColorSpace(vips.InterpretationSRGB); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	format := i.reference.BandFormat()
+
+	if err := i.linear([]float64{sepiaRed, sepiaGreen, sepiaBlue}, []float64{0, 0, 0}); err != nil {
+		return err
+	}
+
+	// Linear produces floating-point pixels, and casting them back clips the
+	// tones brighter than white.
+	if err := i.reference.Cast(format); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// contrast multiplies the distance of each pixel from middle gray by the given
+// factor.
+func (i *Image) contrast(factor float64) error {
+	var (
+		format   = i.reference.BandFormat()
+		white, _ = opaqueValue(format)
+		bands    = i.reference.Bands()
+	)
+
+	// Floating-point pixels range from 0 to 1.
+	if white == 0 {
+		white = 1
+	}
+
+	if i.reference.HasAlpha() {
+		bands--
+	}
+
+	var (
+		a = make([]float64, 0, bands)
+		b = make([]float64, 0, bands)
+	)
+
+	for n := 0; n < bands; n++ {
+		a = append(a, factor)
+		b = append(b, white/2*(1-factor))
+	}
+
+	if err := i.linear(a, b); err != nil {
+		return err
+	}
+
+	if err := i.reference.Cast(format); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// gamma applies the given gamma correction to the color bands of the image
+// using a lookup table.
+func (i *Image) gamma(gamma float64) error {
+	lut, err := gammaLUT(gamma, i.reference.BandFormat())
+	if err != nil {
+		return err
+	}
+	defer lut.Close()
+
+	if !i.reference.HasAlpha() {
+		if err = i.reference.Maplut(lut); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return nil
+	}
+
+	// Leave the alpha channel out, as mapping it would change the
+	// transparency of the image.
+	bands := i.reference.Bands()
+
+	alpha, err := i.reference.ExtractBandToImage(bands-1, 1)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer alpha.Close()
+
+	if err = i.reference.ExtractBand(0, bands-1); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = i.reference.Maplut(lut); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = i.reference.BandJoin(alpha); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// linear multiplies the color bands of the image by a and adds b to them,
+// leaving the alpha channel untouched.
+func (i *Image) linear(a, b []float64) error {
+	if i.reference.HasAlpha() {
+		a = append(a, 1)
+		b = append(b, 0)
+	}
+
+	if err := i.reference.Linear(a, b); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// gammaLUT returns a lookup table applying the given gamma correction to
+// pixels of the given format, which must be 8 or 16 bits unsigned.
+func gammaLUT(gamma float64, format vips.BandFormat) (*vips.ImageRef, error) {
+	var maximum float64
+
+	switch {
+	case format == vips.BandFormatUchar:
+		maximum = 255
+	case format == vips.BandFormatUshort:
+		maximum = 65535
+	default:
+		return nil, fmt.Errorf("%w: gamma needs 8 or 16-bit pixels", ErrUnsupportedBandFormat)
+	}
+
+	// Each entry of the identity table is its own index, which is normalized,
+	// raised to the inverse of the gamma, and scaled back.
+	lut, err := vips.Identity(format == vips.BandFormatUshort)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if err = gammaCurve(lut, gamma, maximum, format); err != nil {
+		lut.Close()
+
+		return nil, err
+	}
+
+	return lut, nil
+}
+
+// gammaCurve maps each entry of the given identity table, ranging from zero to
+// maximum, through the given gamma correction, then casts it back to format.
+func gammaCurve(lut *vips.ImageRef, gamma, maximum float64, format vips.BandFormat) error {
+	if err := lut.Linear1(1/maximum, 0); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := power(lut, 1/gamma); err != nil {
+		return err
+	}
+
+	// Casting truncates, so add a half to round to the nearest value.
+	if err := lut.Linear1(maximum, 0.5); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := lut.Cast(format); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package imgdiet_test
+
+import (
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+func TestImage_Sharpen(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts *imgdiet.SharpenOptions
+		err  error
+	}{
+		{
+			name: "default_options",
+			opts: nil,
+			err:  nil,
+		},
+		{
+			name: "invalid_sigma",
+			opts: &imgdiet.SharpenOptions{
+				Sigma: 0,
+			},
+			err: imgdiet.ErrInvalidSigma,
+		},
+		{
+			name: "invalid_amount",
+			opts: &imgdiet.SharpenOptions{
+				Sigma:  1,
+				Amount: -1,
+			},
+			err: imgdiet.ErrInvalidSharpen,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, _TestDataPath+"/"+_TestValidImagePNG)
+
+			err := image.Sharpen(tt.opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestImage_Blur(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		sigma float64
+		err   error
+	}{
+		{
+			name:  "valid_sigma",
+			sigma: 20,
+			err:   nil,
+		},
+		{
+			name:  "invalid_sigma",
+			sigma: -1,
+			err:   imgdiet.ErrInvalidSigma,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, _TestDataPath+"/"+_TestValidImagePNG)
+
+			err := image.Blur(tt.sigma)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if _, err = image.Optimize(nil); err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestImage_Adjust(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		give        string
+		adjustments *imgdiet.Adjustments
+		err         error
+	}{
+		{
+			name:        "default_adjustments",
+			give:        _TestDataPath + "/" + _TestValidImageJPG,
+			adjustments: imgdiet.DefaultAdjustments(),
+			err:         nil,
+		},
+		{
+			name: "all_adjustments",
+			give: _TestDataPath + "/" + _TestValidImageJPG,
+			adjustments: &imgdiet.Adjustments{
+				Brightness: 1.1,
+				Contrast:   1.2,
+				Saturation: 0.8,
+				Gamma:      1.4,
+			},
+			err: nil,
+		},
+		{
+			name: "transparent_PNG_image",
+			give: _TestDataPath + "/" + _TestTransparentImagePNG,
+			adjustments: &imgdiet.Adjustments{
+				Brightness: 1,
+				Contrast:   0.5,
+				Saturation: 1,
+				Gamma:      0.7,
+			},
+			err: nil,
+		},
+		{
+			name: "16-bit_PNG_image",
+			give: _TestDataPath + "/" + _TestHighDepthImagePNG,
+			adjustments: &imgdiet.Adjustments{
+				Brightness: 1,
+				Contrast:   1,
+				Saturation: 1,
+				Gamma:      2.2,
+			},
+			err: nil,
+		},
+		{
+			name: "negative_contrast",
+			give: _TestDataPath + "/" + _TestValidImageJPG,
+			adjustments: &imgdiet.Adjustments{
+				Brightness: 1,
+				Contrast:   -1,
+				Saturation: 1,
+				Gamma:      1,
+			},
+			err: imgdiet.ErrInvalidAdjustment,
+		},
+		{
+			name: "zero_gamma",
+			give: _TestDataPath + "/" + _TestValidImageJPG,
+			adjustments: &imgdiet.Adjustments{
+				Brightness: 1,
+				Contrast:   1,
+				Saturation: 1,
+			},
+			err: imgdiet.ErrInvalidAdjustment,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, tt.give)
+			hasAlpha := image.HasAlpha()
+
+			err := image.Adjust(tt.adjustments)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if image.HasAlpha() != hasAlpha {
+				t.Errorf("expected alpha channel %t, got %t", hasAlpha, image.HasAlpha())
+			}
+
+			if _, err = image.Optimize(nil); err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestImage_Grayscale(t *testing.T) {
+	t.Parallel()
+
+	image := openTestImage(t, _TestDataPath+"/"+_TestValidImageJPG)
+
+	if err := image.Grayscale(); err != nil {
+		t.Fatalf("Grayscale() failed: %v", err)
+	}
+
+	if image.Metadata().ICCProfile != nil {
+		t.Error("expected ICC profile to be removed")
+	}
+
+	if _, err := image.Optimize(nil); err != nil {
+		t.Fatalf("Optimize() failed: %v", err)
+	}
+}
+
+func TestImage_Sepia(t *testing.T) {
+	t.Parallel()
+
+	image := openTestImage(t, _TestDataPath+"/"+_TestTransparentImagePNG)
+
+	if err := image.Sepia(); err != nil {
+		t.Fatalf("Sepia() failed: %v", err)
+	}
+
+	if !image.HasAlpha() {
+		t.Error("expected alpha channel to be kept")
+	}
+
+	if _, err := image.Optimize(nil); err != nil {
+		t.Fatalf("Optimize() failed: %v", err)
+	}
+}
@@ -4,7 +4,9 @@ go 1.20
 
 require (
 	git.sr.ht/~jamesponddotco/xstd-go v0.0.0-20230602124145-693a263541a3
+	github.com/BurntSushi/toml v1.3.2
 	github.com/davidbyttow/govips/v2 v2.14.0
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/urfave/cli/v2 v2.25.7
 )
 
@@ -14,5 +16,6 @@ require (
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
 	golang.org/x/image v0.10.0 // indirect
 	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 	golang.org/x/text v0.13.0 // indirect
 )
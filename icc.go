@@ -0,0 +1,146 @@
+package imgdiet
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+	"unicode/utf16"
+)
+
+// iccWideGamutThreshold is how much larger than the sRGB gamut, measured as
+// the area of its triangle in the CIE xy chromaticity diagram, the gamut of a
+// profile must be to be considered wide. It leaves room for the rounding
+// errors of the many slightly different sRGB profiles in use.
+const iccWideGamutThreshold float64 = 1.05
+
+// iccTag returns the data of the tag with the given signature in the given ICC
+// profile, or nil if the profile has no such tag or is malformed.
+func iccTag(profile []byte, signature string) []byte {
+	const (
+		headerSize = 128
+		entrySize  = 12
+	)
+
+	if len(profile) < headerSize+4 {
+		return nil
+	}
+
+	count := int(binary.BigEndian.Uint32(profile[headerSize:]))
+
+	for n := 0; n < count; n++ {
+		entry := headerSize + 4 + n*entrySize
+		if entry+entrySize > len(profile) {
+			return nil
+		}
+
+		if string(profile[entry:entry+4]) != signature {
+			continue
+		}
+
+		offset := int(binary.BigEndian.Uint32(profile[entry+4:]))
+		size := int(binary.BigEndian.Uint32(profile[entry+8:]))
+
+		if offset < 0 || size < 12 || offset+size > len(profile) {
+			return nil
+		}
+
+		return profile[offset : offset+size]
+	}
+
+	return nil
+}
+
+// iccDescription returns the description of the given ICC profile, or an
+// empty string if it has none or the profile is malformed. Both the textual
+// description type of version 2 profiles and the multi-localized Unicode type
+// of version 4 profiles are supported.
+func iccDescription(profile []byte) string {
+	tag := iccTag(profile, "desc")
+	if tag == nil {
+		return ""
+	}
+
+	return iccText(tag)
+}
+
+// iccText decodes an ICC textDescriptionType or multiLocalizedUnicodeType tag.
+func iccText(tag []byte) string {
+	switch string(tag[:4]) {
+	case "desc":
+		length := int(binary.BigEndian.Uint32(tag[8:]))
+		if length > len(tag)-12 {
+			return ""
+		}
+
+		return strings.TrimRight(string(tag[12:12+length]), "\x00")
+	case "mluc":
+		if len(tag) < 28 {
+			return ""
+		}
+
+		// Use the first record, which is the default language of the
+		// profile.
+		length := int(binary.BigEndian.Uint32(tag[20:]))
+		offset := int(binary.BigEndian.Uint32(tag[24:]))
+
+		if length%2 != 0 || offset < 0 || offset+length > len(tag) {
+			return ""
+		}
+
+		text := make([]uint16, 0, length/2)
+		for n := 0; n < length; n += 2 {
+			text = append(text, binary.BigEndian.Uint16(tag[offset+n:]))
+		}
+
+		return strings.TrimRight(string(utf16.Decode(text)), "\x00")
+	default:
+		return ""
+	}
+}
+
+// iccWideGamut reports whether the RGB colorants of the given ICC profile span
+// a gamut noticeably wider than sRGB. Profiles without colorants, such as CMYK
+// and grayscale profiles, are never considered wide.
+func iccWideGamut(profile []byte) bool {
+	var primaries [3][2]float64
+
+	for n, signature := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		tag := iccTag(profile, signature)
+		if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+			return false
+		}
+
+		var (
+			x = iccFixed(tag[8:])
+			y = iccFixed(tag[12:])
+			z = iccFixed(tag[16:])
+		)
+
+		sum := x + y + z
+		if sum <= 0 {
+			return false
+		}
+
+		primaries[n] = [2]float64{x / sum, y / sum}
+	}
+
+	// The colorants of sRGB, adapted to the D50 illuminant used by ICC
+	// profiles.
+	srgb := [3][2]float64{
+		{0.6485, 0.3309},
+		{0.3212, 0.5979},
+		{0.1559, 0.0660},
+	}
+
+	return triangleArea(primaries) > triangleArea(srgb)*iccWideGamutThreshold
+}
+
+// iccFixed decodes an ICC s15Fixed16Number.
+func iccFixed(data []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(data))) / 65536
+}
+
+// triangleArea returns the area of the triangle with the given vertices.
+func triangleArea(p [3][2]float64) float64 {
+	return math.Abs(p[0][0]*(p[1][1]-p[2][1])+p[1][0]*(p[2][1]-p[0][1])+p[2][0]*(p[0][1]-p[1][1])) / 2
+}
@@ -3,7 +3,6 @@ package imgdiet
 import (
 	"fmt"
 	"io"
-	"math"
 
 	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
 	"github.com/davidbyttow/govips/v2/vips"
@@ -13,10 +12,43 @@ const (
 	ErrOpenImage               xerrors.Error = "failed to open image"
 	ErrNilImage                xerrors.Error = "image is nil"
 	ErrInvalidResizeDimensions xerrors.Error = "dimensions must be greater than 0"
+	ErrInvalidDPR              xerrors.Error = "device pixel ratio must not be negative"
+)
+
+// Limits and defaults of the WebP and JPEG XL encoders.
+const (
+	maxWebPEffort      = 6
+	minJXLEffort       = 1
+	defaultJXLDistance = 1.0
 )
 
 // Options represents the parameters used to optimize an image.
 type Options struct {
+	// Metadata defines which metadata fields are kept in the output image. If
+	// set, it takes precedence over StripMetadata.
+	Metadata *MetadataPolicy
+
+	// Background defines the color transparent pixels are blended onto when
+	// the image is flattened. If nil, white is used.
+	Background *Color
+
+	// ColorProfile defines the ICC profile the image is converted to before
+	// being optimized, either ColorProfileSRGB or the path of an ICC profile
+	// file. If empty, the colors of the image are left untouched.
+	//
+	// WebP images are always converted to sRGB, as the encoder cannot embed
+	// other profiles.
+	ColorProfile string
+
+	// Format defines the type of the output image, such as ImageTypeWebP to
+	// convert an animated GIF into an animated WebP. If empty, the image keeps
+	// its type. Animated images can only be converted to GIF or WebP.
+	Format string
+
+	// RenderingIntent defines how colors outside of the gamut of ColorProfile
+	// are brought into it. Defaults to IntentPerceptual.
+	RenderingIntent Intent
+
 	// Quality defines the quality of the output image. It is a number between 0
 	// and 100.
 	Quality uint
@@ -28,9 +60,10 @@ type Options struct {
 	Compression uint
 
 	// Effort defines the level of CPU effort to be used when optimizing the
-	// output image. It is a number between 0 and 9.
+	// output image. It is a number between 0 and 9, capped at 6 for WebP
+	// images and raised to 1 for JPEG XL images.
 	//
-	// Only valid for GIF images.
+	// Only valid for GIF, WebP, and JPEG XL images.
 	Effort uint
 
 	// QuantTable defines the quantization table to be used for the output
@@ -42,9 +75,18 @@ type Options struct {
 	// Bitdepth defines the number of bits per pixel of the output image. It is
 	// a number between 1 and 8.
 	//
-	// Only valid for GIF and PNG images.
+	// Only valid for GIF and PNG images. See PreserveDepth for 16-bit PNG
+	// images.
 	Bitdepth uint
 
+	// Distance defines the maximum visual difference between the output image
+	// and the original one, in butteraugli units. It is a floating-point
+	// number between 0 and 25, where 1 is visually lossless. If zero, Quality
+	// is used instead.
+	//
+	// Only valid for JPEG XL images.
+	Distance float64
+
 	// Dither defines the amount of dithering to be applied during 8bpp (bits
 	// per pixel) quantization. It is a floating-point number between 0 and 1.
 	//
@@ -60,14 +102,72 @@ type Options struct {
 	// Interlaced defines whether the output image should be interlaced.
 	Interlaced bool
 
+	// Lossless defines whether the output image should be compressed without
+	// losing any detail, ignoring Quality.
+	//
+	// Unedited JPEG images converted to JPEG XL are recompressed without being
+	// decoded, so the original file can be reconstructed from the output,
+	// which is usually about 20% smaller, or ErrJPEGReconstruction is returned
+	// if libjxl cannot recompress them. Only Effort and StripMetadata apply to
+	// them. Options changing their pixels or metadata otherwise, such as
+	// applying their orientation, converting them to ColorProfile, or setting
+	// a metadata policy, make them be encoded from their decoded pixels like
+	// edited JPEG images, which usually produces a file larger than the
+	// original.
+	//
+	// Only valid for WebP and JPEG XL images.
+	Lossless bool
+
 	// StripMetadata defines whether the output image should have its metadata
-	// stripped.
+	// stripped. Use Metadata to keep some of it.
 	StripMetadata bool
 
 	// OptimizeICCProfile defines whether the output image should have its ICC
 	// profile optimized.
+	//
+	// As the optimized profile is always sRGB, it is ignored when ColorProfile
+	// names another profile.
 	OptimizeICCProfile bool
 
+	// PreserveWideGamut defines whether images with an ICC profile describing
+	// a color space wider than sRGB, such as Display P3 or Adobe RGB, keep it
+	// instead of being converted to sRGB by OptimizeICCProfile. The profile is
+	// kept even when StripMetadata is true.
+	//
+	// Only valid for JPEG, PNG, TIFF, and JPEG XL images.
+	PreserveWideGamut bool
+
+	// PreserveDepth defines whether images with 16 bits per channel keep them
+	// instead of being reduced to Bitdepth.
+	//
+	// Only valid for PNG images.
+	PreserveDepth bool
+
+	// Flatten defines whether the alpha channel of the image should be
+	// removed, blending transparent pixels onto Background.
+	//
+	// JPEG images are always flattened, as the format does not support
+	// transparency.
+	Flatten bool
+
+	// DropOpaqueAlpha defines whether the alpha channel of the image should be
+	// removed when every pixel is fully opaque.
+	DropOpaqueAlpha bool
+
+	// AutoOrient defines whether the image should be rotated and flipped
+	// according to its EXIF orientation tag before being resized or optimized.
+	//
+	// The orientation is always applied when StripMetadata is true, as
+	// stripping the tag would otherwise leave the image sideways or upside
+	// down, unless KeepStoredOrientation is set.
+	AutoOrient bool
+
+	// KeepStoredOrientation defines whether the image pixels should be left as
+	// stored, ignoring its EXIF orientation tag. It takes precedence over
+	// AutoOrient and StripMetadata, so a stripped image stored sideways or
+	// upside down is displayed that way.
+	KeepStoredOrientation bool
+
 	// TrellisQuant defines whether the output image should have its
 	// quantization tables optimized using trellis quantization.
 	//
@@ -99,29 +199,109 @@ func DefaultOptions() *Options {
 		Interlaced:         false,
 		StripMetadata:      true,
 		OptimizeICCProfile: true,
+		DropOpaqueAlpha:    true,
+		AutoOrient:         true,
 		TrellisQuant:       true,
 		OvershootDeringing: true,
 		OptimizeScans:      true,
 	}
 }
 
+// clone returns a deep copy of the Options.
+func (o *Options) clone() *Options {
+	opts := *o
+	opts.Metadata = o.Metadata.clone()
+
+	if o.Background != nil {
+		background := *o.Background
+		opts.Background = &background
+	}
+
+	return &opts
+}
+
 // Image defines an image to be optimized and manages its lifecycle.
 type Image struct {
 	// reference is a govips.ImageRef that contains the image data.
 	reference *vips.ImageRef
 
+	// stamped holds the names of the EXIF tags set with SetEXIF, which are
+	// kept when metadata is stripped.
+	stamped map[string]struct{}
+
 	// format is a string representation of the image type.
 	format string
 
+	// xmp is the XMP packet set with SetXMP, or nil if none was.
+	xmp []byte
+
+	// source is the encoded image the pixels were decoded from, or nil once
+	// they or their metadata were modified. It allows decoding the image again
+	// at a smaller size with shrink-on-load.
+	source []byte
+
 	// size is the size of the image in bytes.
 	size int64
 
 	// saved is the size of the image after optimization in bytes.
 	saved int64
+
+	// dpr is the device pixel ratio achieved by the last resize, or 0 if the
+	// image was not resized.
+	dpr float64
+
+	// orientation is the EXIF orientation applied to the image pixels, or 0 if
+	// none was.
+	orientation int
+}
+
+// OpenOptions represents the parameters used to decode an image.
+type OpenOptions struct {
+	// Page is the page of multi-page TIFF images and PDF documents to open,
+	// counting from 0. Every frame of animated images is always opened.
+	Page uint
+
+	// DPI is the resolution PDF documents and SVG images are rasterized at, in
+	// dots per inch. If zero, 72 is used.
+	DPI uint
+
+	// AllowExternalReferences defines whether SVG images may reference files
+	// and URLs outside of themselves, such as linked images and stylesheets.
+	// If false, opening such images fails with ErrExternalReference, as
+	// rasterizing untrusted images could otherwise read local files.
+	AllowExternalReferences bool
+}
+
+// DefaultOpenOptions returns a set of defaults for opening the first page of
+// an image, rasterizing documents at screen resolution.
+func DefaultOpenOptions() *OpenOptions {
+	return &OpenOptions{
+		Page: 0,
+		DPI:  72,
+	}
 }
 
 // Open takes an io.Reader as input for reading and returns an Image instance.
 func Open(r io.Reader) (*Image, error) {
+	return OpenWithOptions(r, nil)
+}
+
+// OpenWithOptions takes an io.Reader as input for reading and returns an Image
+// instance decoded according to the given OpenOptions, or DefaultOpenOptions
+// if nil.
+//
+// PDF documents and SVG images are rasterized into a regular image, which is
+// optimized as a PNG image unless Options.Format says otherwise. SVG images are
+// rasterized again at the size given to Resize, so they stay sharp at any
+// size.
+//
+// JPEG XL images wrapped in a container are decoded from their codestream, and
+// the Exif and XMP metadata stored in the container is not read.
+func OpenWithOptions(r io.Reader, opts *OpenOptions) (*Image, error) {
+	if opts == nil {
+		opts = DefaultOpenOptions()
+	}
+
 	if r == nil {
 		return nil, fmt.Errorf("%w: %w", ErrOpenImage, ErrNilImage)
 	}
@@ -131,23 +311,71 @@ func Open(r io.Reader) (*Image, error) {
 		return nil, fmt.Errorf("%w: %w", ErrOpenImage, err)
 	}
 
-	data, err := vips.NewImageFromBuffer(image)
+	imageType, err := DetectImageType(image)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrOpenImage, err)
 	}
 
-	imageType, err := DetectImageType(image)
+	if imageType == ImageTypeSVG && !opts.AllowExternalReferences {
+		if err = checkSVGReferences(image); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrOpenImage, err)
+		}
+	}
+
+	size := DetectImageSize(image)
+
+	if imageType == ImageTypeJXL {
+		image, err = jxlBareCodestream(image)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrOpenImage, err)
+		}
+	}
+
+	data, err := vips.LoadImageFromBuffer(image, importParams(imageType, opts))
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrOpenImage, err)
 	}
 
+	source := image
+
+	// Shrink-on-load always decodes the first page of the image.
+	if opts.Page > 0 {
+		source = nil
+	}
+
 	return &Image{
 		reference: data,
 		format:    imageType,
-		size:      DetectImageSize(image),
+		source:    source,
+		size:      size,
 	}, nil
 }
 
+// importParams returns the parameters used to load images of the given type
+// according to the given OpenOptions, loading every frame of animated ones.
+func importParams(format string, opts *OpenOptions) *vips.ImportParams {
+	params := vips.NewImportParams()
+
+	switch format {
+	case ImageTypeGIF, ImageTypeWebP:
+		params.NumPages.Set(-1)
+	case ImageTypeTIFF:
+		params.Page.Set(int(opts.Page))
+	case ImageTypePDF:
+		params.Page.Set(int(opts.Page))
+
+		if opts.DPI > 0 {
+			params.Density.Set(int(opts.DPI))
+		}
+	case ImageTypeSVG:
+		if opts.DPI > 0 {
+			params.Density.Set(int(opts.DPI))
+		}
+	}
+
+	return params
+}
+
 // Close releases the resources associated with the Image.
 func (i *Image) Close() {
 	if i != nil && i.reference != nil {
@@ -163,18 +391,38 @@ func (i *Image) Optimize(opts *Options) ([]byte, error) {
 		opts = DefaultOptions()
 	}
 
-	if opts.OptimizeICCProfile {
-		if err := i.reference.OptimizeICCProfile(); err != nil {
-			return nil, fmt.Errorf("%w", err)
-		}
+	format, err := i.checkFormat(opts)
+	if err != nil {
+		return nil, err
 	}
 
-	var (
-		image []byte
-		err   error
-	)
+	if i.reconstructible(format, opts) {
+		return i.recompressJPEG(opts)
+	}
 
-	switch i.format {
+	i.modified()
+
+	if err = i.orient(opts); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if err = i.handleAlpha(opts); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	keepProfile, err := i.manageColor(opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	opts, err = i.filterMetadata(opts, keepProfile)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	var image []byte
+
+	switch format {
 	case ImageTypeJPEG:
 		image, err = i.optimizeJPEG(opts)
 		if err != nil {
@@ -190,8 +438,30 @@ func (i *Image) Optimize(opts *Options) ([]byte, error) {
 		if err != nil {
 			return nil, fmt.Errorf("%w", err)
 		}
+	case ImageTypeWebP:
+		image, err = i.optimizeWebP(opts)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	case ImageTypeTIFF:
+		image, err = i.optimizeTIFF(opts)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	case ImageTypeJXL:
+		image, err = i.optimizeJXL(opts)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
 	default:
-		return nil, fmt.Errorf("%w: %s", ErrUnsupportedImageFormat, i.format)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedImageFormat, format)
+	}
+
+	if i.xmp != nil {
+		image, err = embedXMP(format, image, i.xmp)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
 	}
 
 	i.saved = DetectImageSize(image)
@@ -199,39 +469,127 @@ func (i *Image) Optimize(opts *Options) ([]byte, error) {
 	return image, nil
 }
 
+// ResizeOptions represents the parameters used to resize an image.
+type ResizeOptions struct {
+	// Sharpen defines the sharpening applied after resizing, to recover the
+	// details softened by downscaling. If nil, the image is not sharpened.
+	Sharpen *SharpenOptions
+
+	// Width is the width of the resized image in pixels, or in CSS pixels if
+	// DPR is set. If zero, it is computed from Height, keeping the aspect
+	// ratio of the image.
+	Width uint
+
+	// Height is the height of the resized image in pixels, or in CSS pixels if
+	// DPR is set. If zero, it is computed from Width, keeping the aspect ratio
+	// of the image.
+	Height uint
+
+	// Kernel defines the interpolation used to compute the pixels of the
+	// resized image.
+	Kernel Kernel
+
+	// MaxUpscale defines the maximum factor the image may be enlarged by, such
+	// as 2 to double its size at most. Dimensions are capped accordingly. If 1
+	// or less, the image is never enlarged. SVG images are always rasterized at
+	// the requested size.
+	MaxUpscale float64
+
+	// DPR defines the device pixel ratio the image is resized for, such as 2
+	// for a 400 CSS pixels wide image displayed on a high-density screen, which
+	// is then 800 pixels wide. If zero, 1 is used.
+	//
+	// Dimensions are capped at the size of the image unless MaxUpscale allows
+	// enlarging it, so the device pixel ratio achieved, as returned by
+	// Image.DPR, may be lower than the requested one.
+	DPR float64
+
+	// ShrinkOnLoad defines whether the image is decoded again from its source
+	// at a reduced size, which is much faster for large JPEG and WebP images.
+	//
+	// It is only used with KernelDefault, and ignored once the image was
+	// modified by another operation, as the changes would be lost.
+	ShrinkOnLoad bool
+
+	// LowerQuality defines whether Options.Quality is divided by the square
+	// root of the device pixel ratio achieved, such as from 75 to 53 for 2x
+	// images. The artifacts of lower qualities are hard to see on high-density
+	// screens, and this keeps high-DPR variants close to the file size of 1x
+	// ones.
+	LowerQuality bool
+}
+
+// Validate returns an error if the ResizeOptions are out of range.
+func (o *ResizeOptions) Validate() error {
+	if o.Width == 0 && o.Height == 0 {
+		return fmt.Errorf("%w", ErrInvalidResizeDimensions)
+	}
+
+	if o.DPR < 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidDPR, o.DPR)
+	}
+
+	if o.Sharpen != nil {
+		if err := o.Sharpen.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Resize takes a set of dimensions and resizes the image to those dimensions.
 // If opts is not nil, the resulting image is optimized according to the given
 // Options.
+//
+// Images with an alpha channel are resized with their colors premultiplied by
+// it, so transparent pixels do not bleed into the visible ones.
 func (i *Image) Resize(width, height uint, opts *Options) ([]byte, error) {
-	if width == 0 && height == 0 {
+	return i.ResizeWithOptions(&ResizeOptions{
+		Width:  width,
+		Height: height,
+	}, opts)
+}
+
+// ResizeWithOptions resizes the image according to the given ResizeOptions,
+// as done by Resize. If opts is not nil, the resulting image is optimized
+// according to the given Options.
+//
+// The image is made upright according to its EXIF orientation tag before
+// being resized, unless opts keep the stored orientation.
+func (i *Image) ResizeWithOptions(resize *ResizeOptions, opts *Options) ([]byte, error) {
+	if resize == nil {
 		return nil, fmt.Errorf("%w", ErrInvalidResizeDimensions)
 	}
 
-	var (
-		originalWidth  = i.reference.Width()
-		originalHeight = i.reference.Height()
-		aspectRatio    = float64(originalWidth) / float64(originalHeight)
-	)
-
-	if width == 0 {
-		width = uint(math.Round(float64(height) * aspectRatio))
-	} else if height == 0 {
-		height = uint(math.Round(float64(width) / aspectRatio))
+	if err := resize.Validate(); err != nil {
+		return nil, err
 	}
 
-	if int(width) > originalWidth {
-		width = uint(originalWidth)
+	if err := i.orient(opts); err != nil {
+		return nil, fmt.Errorf("%w", err)
 	}
 
-	if int(height) > originalHeight {
-		height = uint(originalHeight)
+	width, height := i.targetSize(resize)
+
+	if err := i.scale(resize, width, height); err != nil {
+		return nil, err
 	}
 
-	if err := i.reference.Thumbnail(int(width), int(height), vips.InterestingCentre); err != nil {
-		return nil, fmt.Errorf("%w", err)
+	i.dpr = resize.achievedDPR(width, height)
+
+	if resize.Sharpen != nil {
+		if err := i.Sharpen(resize.Sharpen); err != nil {
+			return nil, err
+		}
 	}
 
 	if opts != nil {
+		if resize.LowerQuality && i.dpr > 1 {
+			opts = opts.clone()
+			opts.Quality = dprQuality(opts.Quality, i.dpr)
+		}
+
 		return i.Optimize(opts)
 	}
 
@@ -243,6 +601,106 @@ func (i *Image) Resize(width, height uint, opts *Options) ([]byte, error) {
 	return image, nil
 }
 
+// outputFormat returns the type the image is encoded to with the given
+// Options.
+func (i *Image) outputFormat(opts *Options) string {
+	if opts.Format != "" {
+		return opts.Format
+	}
+
+	// Documents and vector images cannot be encoded back, so they are kept as
+	// lossless images to keep their text and edges sharp.
+	if i.format == ImageTypePDF || i.format == ImageTypeSVG {
+		return ImageTypePNG
+	}
+
+	return i.format
+}
+
+// checkFormat returns the type the image is encoded to with the given Options,
+// or an error if the image cannot be encoded to it.
+func (i *Image) checkFormat(opts *Options) (string, error) {
+	format := i.outputFormat(opts)
+
+	switch format {
+	case ImageTypeJPEG, ImageTypePNG, ImageTypeGIF, ImageTypeWebP, ImageTypeTIFF, ImageTypeJXL:
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedImageFormat, format)
+	}
+
+	if i.Frames() > 1 && !animatedFormat(format) {
+		return "", fmt.Errorf("%w: cannot encode %d frames as %s", ErrAnimatedImage, i.Frames(), format)
+	}
+
+	return format, nil
+}
+
+// vector reports whether the image can be rasterized again from its source at
+// any size.
+func (i *Image) vector() bool {
+	return i.format == ImageTypeSVG && i.source != nil
+}
+
+// modified records that the image no longer matches its source, so it cannot
+// be decoded again with shrink-on-load.
+func (i *Image) modified() {
+	i.source = nil
+}
+
+// Format returns the type of the image, as returned by DetectImageType.
+func (i *Image) Format() string {
+	return i.format
+}
+
+// AutoOrient rotates and flips the image according to its EXIF orientation tag
+// and removes the tag. It returns the orientation that was applied, between 2
+// and 8, or 1 if the image was already upright.
+func (i *Image) AutoOrient() (int, error) {
+	if !i.stored() {
+		return 1, nil
+	}
+
+	orientation := i.reference.Orientation()
+
+	i.modified()
+
+	angle, flip := uprightTransform(orientation)
+
+	if angle != vips.Angle0 {
+		if err := i.reference.Rotate(angle); err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+	}
+
+	if flip {
+		if err := i.reference.Flip(vips.DirectionHorizontal); err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := i.reference.RemoveOrientation(); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	i.orientation = orientation
+
+	return orientation, nil
+}
+
+// Orientation returns the EXIF orientation that was applied to the image
+// pixels by AutoOrient, Resize, Optimize, or a geometric transform such as
+// Rotate, or 0 if none was.
+func (i *Image) Orientation() int {
+	return i.orientation
+}
+
+// DPR returns the device pixel ratio achieved by the last call to Resize or
+// ResizeWithOptions, which is lower than the requested one when the image is
+// too small to provide it, or 0 if the image was not resized.
+func (i *Image) DPR() float64 {
+	return i.dpr
+}
+
 // Size returns the size of the image in bytes.
 func (i *Image) Size() int64 {
 	return i.size
@@ -258,9 +716,74 @@ func (i *Image) Width() int {
 	return i.reference.Width()
 }
 
-// Height returns the height of the image in pixels.
+// Height returns the height of the image in pixels. For animated images, it is
+// the height of a single frame.
 func (i *Image) Height() int {
-	return i.reference.Height()
+	return i.reference.PageHeight()
+}
+
+// highDepth reports whether the image uses more than 8 bits per channel.
+func (i *Image) highDepth() bool {
+	format := i.reference.BandFormat()
+
+	return format != vips.BandFormatUchar && format != vips.BandFormatChar && format != vips.BandFormatNotSet
+}
+
+// orient applies the EXIF orientation of the image to its pixels unless the
+// given Options keep the stored orientation. Without Options, as when resizing
+// without optimizing, the orientation is always applied.
+func (i *Image) orient(opts *Options) error {
+	if opts != nil && !opts.appliesOrientation() {
+		return nil
+	}
+
+	if _, err := i.AutoOrient(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// orients reports whether optimizing the image with the given Options applies
+// its EXIF orientation to its pixels.
+func (i *Image) orients(opts *Options) bool {
+	return i.stored() && opts.appliesOrientation()
+}
+
+// appliesOrientation reports whether the Options apply the EXIF orientation of
+// images to their pixels.
+func (o *Options) appliesOrientation() bool {
+	return !o.KeepStoredOrientation && (o.AutoOrient || o.StripMetadata)
+}
+
+// stored reports whether the image has an EXIF orientation tag that was not
+// applied to its pixels.
+func (i *Image) stored() bool {
+	orientation := i.reference.Orientation()
+
+	return orientation >= 2 && orientation <= 8
+}
+
+// uprightTransform returns the clockwise rotation that makes an image stored
+// with the given EXIF orientation upright, and whether it must be flipped
+// horizontally after being rotated.
+func uprightTransform(orientation int) (angle vips.Angle, flip bool) {
+	switch orientation {
+	case 2:
+		return vips.Angle0, true
+	case 3:
+		return vips.Angle180, false
+	case 4:
+		return vips.Angle180, true
+	case 5:
+		return vips.Angle90, true
+	case 6:
+		return vips.Angle90, false
+	case 7:
+		return vips.Angle270, true
+	default:
+		return vips.Angle270, false
+	}
 }
 
 // optimizeJPEG takes the given Options and optimizes the image accordingly. It
@@ -299,6 +822,10 @@ func (i *Image) optimizePNG(opts *Options) ([]byte, error) {
 		Bitdepth:      int(opts.Bitdepth),
 	}
 
+	if opts.PreserveDepth && i.highDepth() {
+		options.Bitdepth = 16
+	}
+
 	image, _, err := i.reference.ExportPng(options)
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
@@ -310,6 +837,11 @@ func (i *Image) optimizePNG(opts *Options) ([]byte, error) {
 // optimizeGIF takes the given Options and optimizes the image accordingly. It
 // returns the optimized image as a byte slice or an error if the optimization
 // fails.
+//
+// Every frame of animated images is encoded with its delay and the loop count.
+// libvips quantizes the frames against a palette shared by the whole animation,
+// only falling back to a local palette for frames it would degrade, and stores
+// the pixels unchanged from the previous frame as transparent.
 func (i *Image) optimizeGIF(opts *Options) ([]byte, error) {
 	options := &vips.GifExportParams{
 		StripMetadata: opts.StripMetadata,
@@ -326,3 +858,90 @@ func (i *Image) optimizeGIF(opts *Options) ([]byte, error) {
 
 	return image, nil
 }
+
+// optimizeWebP takes the given Options and optimizes the image accordingly. It
+// returns the optimized image as a byte slice or an error if the optimization
+// fails.
+//
+// Every frame of animated images is encoded with its delay and the loop count,
+// only storing the changes from the previous frame.
+func (i *Image) optimizeWebP(opts *Options) ([]byte, error) {
+	effort := int(opts.Effort)
+	if effort > maxWebPEffort {
+		effort = maxWebPEffort
+	}
+
+	options := &vips.WebpExportParams{
+		StripMetadata:   opts.StripMetadata,
+		Quality:         int(opts.Quality),
+		Lossless:        opts.Lossless,
+		ReductionEffort: effort,
+	}
+
+	image, _, err := i.reference.ExportWebp(options)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return image, nil
+}
+
+// optimizeTIFF takes the given Options and optimizes the image accordingly. It
+// returns the optimized image as a byte slice or an error if the optimization
+// fails.
+//
+// The image is compressed losslessly with Deflate, which suits scanned
+// documents better than the JPEG compression TIFF also supports.
+func (i *Image) optimizeTIFF(opts *Options) ([]byte, error) {
+	options := &vips.TiffExportParams{
+		StripMetadata: opts.StripMetadata,
+		Compression:   vips.TiffCompressionDeflate,
+		Predictor:     vips.TiffPredictorHorizontal,
+	}
+
+	image, _, err := i.reference.ExportTiff(options)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return image, nil
+}
+
+// optimizeJXL takes the given Options and optimizes the image accordingly. It
+// returns the optimized image as a byte slice or an error if the optimization
+// fails.
+//
+// The JPEG XL encoder cannot strip metadata by itself, so it is removed from
+// the image beforehand, keeping the ICC profile.
+func (i *Image) optimizeJXL(opts *Options) ([]byte, error) {
+	if opts.StripMetadata {
+		if err := i.reference.RemoveMetadata(); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	effort := int(opts.Effort)
+	if effort < minJXLEffort {
+		effort = minJXLEffort
+	}
+
+	options := &vips.JxlExportParams{
+		Quality:  int(opts.Quality),
+		Lossless: opts.Lossless,
+		Distance: defaultJXLDistance,
+		Effort:   effort,
+	}
+
+	// libvips computes the distance from the quality when one is given.
+	if opts.Distance > 0 {
+		options.Quality = 0
+		options.Distance = opts.Distance
+	}
+
+	image, _, err := i.reference.ExportJxl(options)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return image, nil
+}
@@ -1,8 +1,14 @@
 package imgdiet_test
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
 	"io"
 	"os"
 	"path/filepath"
@@ -59,8 +65,23 @@ func TestOpen(t *testing.T) {
 			err:  nil,
 		},
 		{
-			name: "invalid_image",
+			name: "valid_WebP_image",
 			give: _TestDataPath + "/" + _TestValidImageWebP,
+			err:  nil,
+		},
+		{
+			name: "valid_SVG_image",
+			give: _TestDataPath + "/" + _TestVectorImageSVG,
+			err:  nil,
+		},
+		{
+			name: "SVG_image_with_external_reference",
+			give: _TestDataPath + "/" + _TestExternalImageSVG,
+			err:  imgdiet.ErrExternalReference,
+		},
+		{
+			name: "unsupported_file",
+			give: _TestDataPath + "/" + _TestWideGamutProfile,
 			err:  imgdiet.ErrUnsupportedImageFormat,
 		},
 		{
@@ -92,19 +113,156 @@ func TestOpen(t *testing.T) {
 				file = f
 			}
 
-			image, err := imgdiet.Open(file)
+			img, err := imgdiet.Open(file)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			defer img.Close()
+
+			if err != nil {
+				return
+			}
+		})
+	}
+}
+
+func TestOpenWithOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		give       string
+		opts       *imgdiet.OpenOptions
+		wantFormat string
+		wantWidth  int
+		wantHeight int
+		wantColor  color.RGBA
+		err        error
+	}{
+		{
+			name:       "first_TIFF_page",
+			give:       _TestDataPath + "/" + _TestMultiPageImageTIFF,
+			opts:       nil,
+			wantFormat: imgdiet.ImageTypeTIFF,
+			wantWidth:  64,
+			wantHeight: 48,
+			wantColor:  color.RGBA{R: 200, G: 30, B: 30, A: 255},
+			err:        nil,
+		},
+		{
+			name: "second_TIFF_page",
+			give: _TestDataPath + "/" + _TestMultiPageImageTIFF,
+			opts: &imgdiet.OpenOptions{
+				Page: 1,
+			},
+			wantFormat: imgdiet.ImageTypeTIFF,
+			wantWidth:  64,
+			wantHeight: 48,
+			wantColor:  color.RGBA{R: 30, G: 60, B: 200, A: 255},
+			err:        nil,
+		},
+		{
+			name:       "first_PDF_page",
+			give:       _TestDataPath + "/" + _TestDocumentPDF,
+			opts:       nil,
+			wantFormat: imgdiet.ImageTypePNG,
+			wantWidth:  72,
+			wantHeight: 48,
+			wantColor:  color.RGBA{R: 255, A: 255},
+			err:        nil,
+		},
+		{
+			name: "second_PDF_page_at_144_DPI",
+			give: _TestDataPath + "/" + _TestDocumentPDF,
+			opts: &imgdiet.OpenOptions{
+				Page: 1,
+				DPI:  144,
+			},
+			wantFormat: imgdiet.ImageTypePNG,
+			wantWidth:  144,
+			wantHeight: 96,
+			wantColor:  color.RGBA{B: 255, A: 255},
+			err:        nil,
+		},
+		{
+			name: "page_past_last_page",
+			give: _TestDataPath + "/" + _TestDocumentPDF,
+			opts: &imgdiet.OpenOptions{
+				Page: 2,
+			},
+			err: imgdiet.ErrOpenImage,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(tt.give)
+			if err != nil {
+				t.Fatalf("unable to open file: %v", err)
+			}
+			defer file.Close()
+
+			img, err := imgdiet.OpenWithOptions(file, tt.opts)
 			if !errors.Is(err, tt.err) {
 				t.Fatalf("expected error %v, got %v", tt.err, err)
 			}
-			defer image.Close()
 
 			if err != nil {
 				return
 			}
+			defer img.Close()
+
+			if img.Frames() != 1 {
+				t.Errorf("expected a single page, got %d", img.Frames())
+			}
+
+			if img.Width() != tt.wantWidth || img.Height() != tt.wantHeight {
+				t.Errorf("expected %dx%d image, got %dx%d", tt.wantWidth, tt.wantHeight, img.Width(), img.Height())
+			}
+
+			optimized, err := img.Optimize(imgdiet.DefaultOptions())
+			if err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+
+			if got, _ := imgdiet.DetectImageType(optimized); got != tt.wantFormat {
+				t.Fatalf("expected %s image, got %s", tt.wantFormat, got)
+			}
+
+			// Check the page by its color, reading it back as PNG.
+			opts := imgdiet.DefaultOptions()
+			opts.Format = imgdiet.ImageTypePNG
+
+			encoded, err := img.Optimize(opts)
+			if err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+
+			decoded, err := png.Decode(bytes.NewReader(encoded))
+			if err != nil {
+				t.Fatalf("png.Decode() failed: %v", err)
+			}
+
+			r, g, b, _ := decoded.At(decoded.Bounds().Dx()/2, decoded.Bounds().Dy()/2).RGBA()
+			if !closeColor(r>>8, tt.wantColor.R) || !closeColor(g>>8, tt.wantColor.G) || !closeColor(b>>8, tt.wantColor.B) {
+				t.Errorf("expected color %v, got %d,%d,%d", tt.wantColor, r>>8, g>>8, b>>8)
+			}
 		})
 	}
 }
 
+// closeColor reports whether the given channel values differ by at most 2,
+// allowing for rounding in color conversions.
+func closeColor(got uint32, want uint8) bool {
+	diff := int(got) - int(want)
+
+	return diff >= -2 && diff <= 2
+}
+
 func TestImage_Optimize(t *testing.T) {
 	t.Parallel()
 
@@ -292,6 +450,228 @@ func TestImage_Resize(t *testing.T) {
 	}
 }
 
+func TestImage_ResizeWithOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		resize         *imgdiet.ResizeOptions
+		expectedWidth  int
+		expectedHeight int
+		err            error
+	}{
+		{
+			name: "sharpened",
+			resize: &imgdiet.ResizeOptions{
+				Sharpen: imgdiet.DefaultSharpenOptions(),
+				Width:   500,
+			},
+			expectedWidth:  500,
+			expectedHeight: 750,
+			err:            nil,
+		},
+		{
+			name: "nearest_kernel",
+			resize: &imgdiet.ResizeOptions{
+				Width:  100,
+				Height: 100,
+				Kernel: imgdiet.KernelNearest,
+			},
+			expectedWidth:  100,
+			expectedHeight: 100,
+			err:            nil,
+		},
+		{
+			name: "mitchell_kernel_with_only_width",
+			resize: &imgdiet.ResizeOptions{
+				Width:  500,
+				Kernel: imgdiet.KernelMitchell,
+			},
+			expectedWidth:  500,
+			expectedHeight: 750,
+			err:            nil,
+		},
+		{
+			name: "upscale",
+			resize: &imgdiet.ResizeOptions{
+				Width:      3000,
+				MaxUpscale: 2,
+			},
+			expectedWidth:  3000,
+			expectedHeight: 4501,
+			err:            nil,
+		},
+		{
+			name: "upscale_capped_by_factor",
+			resize: &imgdiet.ResizeOptions{
+				Width:      3000,
+				Kernel:     imgdiet.KernelLanczos3,
+				MaxUpscale: 1.5,
+			},
+			expectedWidth:  2318,
+			expectedHeight: 3477,
+			err:            nil,
+		},
+		{
+			name: "shrink_on_load",
+			resize: &imgdiet.ResizeOptions{
+				Width:        200,
+				ShrinkOnLoad: true,
+			},
+			expectedWidth:  200,
+			expectedHeight: 300,
+			err:            nil,
+		},
+		{
+			name: "invalid_sharpen_options",
+			resize: &imgdiet.ResizeOptions{
+				Sharpen: &imgdiet.SharpenOptions{},
+				Width:   500,
+			},
+			err: imgdiet.ErrInvalidSigma,
+		},
+		{
+			name:   "nil_resize_options",
+			resize: nil,
+			err:    imgdiet.ErrInvalidResizeDimensions,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			img := openTestImage(t, filepath.Join(_TestDataPath, _TestValidImageJPG))
+
+			_, err := img.ResizeWithOptions(tt.resize, imgdiet.DefaultOptions())
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if img.Width() != tt.expectedWidth || img.Height() != tt.expectedHeight {
+				t.Errorf("expected %dx%d image, got %dx%d", tt.expectedWidth, tt.expectedHeight, img.Width(), img.Height())
+			}
+		})
+	}
+}
+
+func TestImage_ResizeWithOptions_DPR(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		resize         *imgdiet.ResizeOptions
+		expectedWidth  int
+		expectedHeight int
+		expectedDPR    float64
+		err            error
+	}{
+		{
+			name: "double_density",
+			resize: &imgdiet.ResizeOptions{
+				Width: 400,
+				DPR:   2,
+			},
+			expectedWidth:  800,
+			expectedHeight: 1200,
+			expectedDPR:    2,
+			err:            nil,
+		},
+		{
+			name: "capped_at_source_size",
+			resize: &imgdiet.ResizeOptions{
+				Width: 600,
+				DPR:   3,
+			},
+			expectedWidth:  1545,
+			expectedHeight: 2318,
+			expectedDPR:    2.575,
+			err:            nil,
+		},
+		{
+			name: "default_density",
+			resize: &imgdiet.ResizeOptions{
+				Width: 400,
+			},
+			expectedWidth:  400,
+			expectedHeight: 600,
+			expectedDPR:    1,
+			err:            nil,
+		},
+		{
+			name: "negative_density",
+			resize: &imgdiet.ResizeOptions{
+				Width: 400,
+				DPR:   -2,
+			},
+			err: imgdiet.ErrInvalidDPR,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			img := openTestImage(t, filepath.Join(_TestDataPath, _TestValidImageJPG))
+
+			_, err := img.ResizeWithOptions(tt.resize, nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if img.Width() != tt.expectedWidth || img.Height() != tt.expectedHeight {
+				t.Errorf("expected %dx%d image, got %dx%d", tt.expectedWidth, tt.expectedHeight, img.Width(), img.Height())
+			}
+
+			if img.DPR() != tt.expectedDPR {
+				t.Errorf("expected DPR %v, got %v", tt.expectedDPR, img.DPR())
+			}
+		})
+	}
+}
+
+func TestImage_ResizeWithOptions_LowerQuality(t *testing.T) {
+	t.Parallel()
+
+	resize := func(lower bool) []byte {
+		t.Helper()
+
+		img := openTestImage(t, filepath.Join(_TestDataPath, _TestValidImageJPG))
+
+		got, err := img.ResizeWithOptions(&imgdiet.ResizeOptions{
+			Width:        400,
+			DPR:          2,
+			LowerQuality: lower,
+		}, imgdiet.DefaultOptions())
+		if err != nil {
+			t.Fatalf("ResizeWithOptions() failed: %v", err)
+		}
+
+		return got
+	}
+
+	var (
+		regular = resize(false)
+		lowered = resize(true)
+	)
+
+	if len(lowered) >= len(regular) {
+		t.Errorf("expected lowered quality image to be smaller than %d bytes, got %d", len(regular), len(lowered))
+	}
+}
+
 func TestImage_SizeAndSaved(t *testing.T) {
 	t.Parallel()
 
@@ -353,3 +733,526 @@ func TestImage_SizeAndSaved(t *testing.T) {
 		})
 	}
 }
+
+func TestImage_Format(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{
+			name: "valid_JPEG_image",
+			file: filepath.Join(_TestDataPath, _TestValidImageJPG),
+			want: imgdiet.ImageTypeJPEG,
+		},
+		{
+			name: "valid_PNG_image",
+			file: filepath.Join(_TestDataPath, _TestValidImagePNG),
+			want: imgdiet.ImageTypePNG,
+		},
+		{
+			name: "valid_GIF_image",
+			file: filepath.Join(_TestDataPath, _TestValidImageGIF),
+			want: imgdiet.ImageTypeGIF,
+		},
+		{
+			name: "valid_WebP_image",
+			file: filepath.Join(_TestDataPath, _TestValidImageWebP),
+			want: imgdiet.ImageTypeWebP,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(tt.file)
+			if err != nil {
+				t.Fatalf("unable to open file: %v", err)
+			}
+			defer file.Close()
+
+			img, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer img.Close()
+
+			if got := img.Format(); got != tt.want {
+				t.Errorf("Image.Format() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImage_AutoOrient(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		file            string
+		wantOrientation int
+		wantWidth       int
+		wantHeight      int
+	}{
+		{
+			name:            "rotated_JPEG_image",
+			file:            filepath.Join(_TestDataPath, _TestRotatedImageJPG),
+			wantOrientation: 6,
+			wantWidth:       40,
+			wantHeight:      60,
+		},
+		{
+			name:            "upright_PNG_image",
+			file:            filepath.Join(_TestDataPath, _TestValidImagePNG),
+			wantOrientation: 1,
+			wantWidth:       0,
+			wantHeight:      0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(tt.file)
+			if err != nil {
+				t.Fatalf("unable to open file: %v", err)
+			}
+			defer file.Close()
+
+			img, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer img.Close()
+
+			var (
+				originalWidth  = img.Width()
+				originalHeight = img.Height()
+			)
+
+			got, err := img.AutoOrient()
+			if err != nil {
+				t.Fatalf("Image.AutoOrient() failed: %v", err)
+			}
+
+			if got != tt.wantOrientation {
+				t.Errorf("Image.AutoOrient() = %d, want %d", got, tt.wantOrientation)
+			}
+
+			if tt.wantWidth == 0 {
+				if img.Width() != originalWidth || img.Height() != originalHeight {
+					t.Errorf("Image.AutoOrient() changed dimensions of an upright image")
+				}
+
+				if img.Orientation() != 0 {
+					t.Errorf("Image.Orientation() = %d, want 0", img.Orientation())
+				}
+
+				return
+			}
+
+			if img.Width() != tt.wantWidth || img.Height() != tt.wantHeight {
+				t.Errorf("Image.AutoOrient() got width = %d, height = %d, want width = %d, height = %d",
+					img.Width(), img.Height(), tt.wantWidth, tt.wantHeight)
+			}
+
+			if got, _ = img.AutoOrient(); got != 1 {
+				t.Errorf("Image.AutoOrient() applied the orientation twice, got %d", got)
+			}
+
+			if img.Orientation() != tt.wantOrientation {
+				t.Errorf("Image.Orientation() = %d, want %d", img.Orientation(), tt.wantOrientation)
+			}
+		})
+	}
+}
+
+func TestImage_Optimize_AutoOrient(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		opts            *imgdiet.Options
+		wantOrientation int
+		wantWidth       int
+		wantHeight      int
+	}{
+		{
+			name:            "auto_orient",
+			opts:            &imgdiet.Options{AutoOrient: true},
+			wantOrientation: 6,
+			wantWidth:       40,
+			wantHeight:      60,
+		},
+		{
+			name:            "strip_metadata",
+			opts:            &imgdiet.Options{StripMetadata: true},
+			wantOrientation: 6,
+			wantWidth:       40,
+			wantHeight:      60,
+		},
+		{
+			name:            "keep_metadata",
+			opts:            &imgdiet.Options{},
+			wantOrientation: 0,
+			wantWidth:       60,
+			wantHeight:      40,
+		},
+		{
+			name: "keep_stored_orientation",
+			opts: &imgdiet.Options{
+				StripMetadata:         true,
+				AutoOrient:            true,
+				KeepStoredOrientation: true,
+			},
+			wantOrientation: 0,
+			wantWidth:       60,
+			wantHeight:      40,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			img := openTestImage(t, filepath.Join(_TestDataPath, _TestRotatedImageJPG))
+
+			if _, err := img.Optimize(tt.opts); err != nil {
+				t.Fatalf("Image.Optimize() failed: %v", err)
+			}
+
+			if img.Orientation() != tt.wantOrientation || img.Width() != tt.wantWidth || img.Height() != tt.wantHeight {
+				t.Errorf("Image.Optimize() got orientation = %d, width = %d, height = %d, want orientation = %d, width = %d, height = %d",
+					img.Orientation(), img.Width(), img.Height(), tt.wantOrientation, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+// orientedJPEG returns a 32x16 JPEG image with a black 8x8 marker in its
+// top-left corner and the given EXIF orientation tag.
+func orientedJPEG(t *testing.T, orientation int) []byte {
+	t.Helper()
+
+	img := image.NewGray(image.Rect(0, 0, 32, 16))
+
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			if x >= 8 || y >= 8 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+
+	if err := jpeg.Encode(&buffer, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("jpeg.Encode() failed: %v", err)
+	}
+
+	// A big-endian TIFF header followed by an IFD holding only the
+	// orientation tag.
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00")
+	exif[25] = byte(orientation)
+
+	segment := []byte{0xff, 0xe1, 0x00, 0x00}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(exif)+2))
+
+	data := buffer.Bytes()
+
+	return append(append(append([]byte{}, data[:2]...), append(segment, exif...)...), data[2:]...)
+}
+
+func TestImage_AutoOrient_Mirrored(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		orientation int
+		wantWidth   int
+		wantHeight  int
+		// wantX and wantY are the coordinates of the marker once the image is
+		// upright.
+		wantX int
+		wantY int
+	}{
+		{
+			name:        "mirror_horizontal",
+			orientation: 2,
+			wantWidth:   32,
+			wantHeight:  16,
+			wantX:       28,
+			wantY:       4,
+		},
+		{
+			name:        "rotate_180",
+			orientation: 3,
+			wantWidth:   32,
+			wantHeight:  16,
+			wantX:       28,
+			wantY:       12,
+		},
+		{
+			name:        "mirror_vertical",
+			orientation: 4,
+			wantWidth:   32,
+			wantHeight:  16,
+			wantX:       4,
+			wantY:       12,
+		},
+		{
+			name:        "transpose",
+			orientation: 5,
+			wantWidth:   16,
+			wantHeight:  32,
+			wantX:       4,
+			wantY:       4,
+		},
+		{
+			name:        "rotate_90",
+			orientation: 6,
+			wantWidth:   16,
+			wantHeight:  32,
+			wantX:       12,
+			wantY:       4,
+		},
+		{
+			name:        "transverse",
+			orientation: 7,
+			wantWidth:   16,
+			wantHeight:  32,
+			wantX:       12,
+			wantY:       28,
+		},
+		{
+			name:        "rotate_270",
+			orientation: 8,
+			wantWidth:   16,
+			wantHeight:  32,
+			wantX:       4,
+			wantY:       28,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			img, err := imgdiet.Open(bytes.NewReader(orientedJPEG(t, tt.orientation)))
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer img.Close()
+
+			got, err := img.AutoOrient()
+			if err != nil {
+				t.Fatalf("Image.AutoOrient() failed: %v", err)
+			}
+
+			if got != tt.orientation {
+				t.Errorf("Image.AutoOrient() = %d, want %d", got, tt.orientation)
+			}
+
+			data, err := img.Optimize(&imgdiet.Options{Format: imgdiet.ImageTypePNG})
+			if err != nil {
+				t.Fatalf("Image.Optimize() failed: %v", err)
+			}
+
+			upright, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("png.Decode() failed: %v", err)
+			}
+
+			bounds := upright.Bounds()
+			if bounds.Dx() != tt.wantWidth || bounds.Dy() != tt.wantHeight {
+				t.Fatalf("Image.AutoOrient() got width = %d, height = %d, want width = %d, height = %d",
+					bounds.Dx(), bounds.Dy(), tt.wantWidth, tt.wantHeight)
+			}
+
+			corners := []image.Point{
+				{X: 4, Y: 4},
+				{X: bounds.Dx() - 4, Y: 4},
+				{X: 4, Y: bounds.Dy() - 4},
+				{X: bounds.Dx() - 4, Y: bounds.Dy() - 4},
+			}
+
+			for _, corner := range corners {
+				var (
+					gray, _, _, _ = upright.At(corner.X, corner.Y).RGBA()
+					want          = corner.X == tt.wantX && corner.Y == tt.wantY
+				)
+
+				if (gray>>8 < 128) != want {
+					t.Errorf("pixel at %v = %d, want marker %v", corner, gray>>8, want)
+				}
+			}
+		})
+	}
+}
+
+func TestImage_Resize_AutoOrient(t *testing.T) {
+	t.Parallel()
+
+	img := openTestImage(t, filepath.Join(_TestDataPath, _TestRotatedImageJPG))
+
+	if _, err := img.Resize(20, 0, nil); err != nil {
+		t.Fatalf("Image.Resize() failed: %v", err)
+	}
+
+	if img.Orientation() != 6 || img.Width() != 20 || img.Height() != 30 {
+		t.Errorf("Image.Resize() got orientation = %d, width = %d, height = %d, want orientation = 6, width = 20, height = 30",
+			img.Orientation(), img.Width(), img.Height())
+	}
+}
+
+func TestImage_Optimize_JXL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		give        string
+		opts        *imgdiet.Options
+		err         error
+		reconstruct bool
+	}{
+		{
+			name: "JPEG_image_at_distance",
+			give: _TestDataPath + "/" + _TestValidImageJPG,
+			opts: &imgdiet.Options{
+				Format:        imgdiet.ImageTypeJXL,
+				Distance:      1.5,
+				Effort:        3,
+				StripMetadata: true,
+			},
+			err:         nil,
+			reconstruct: false,
+		},
+		{
+			name: "JPEG_image_at_quality",
+			give: _TestDataPath + "/" + _TestValidImageJPG,
+			opts: &imgdiet.Options{
+				Format:  imgdiet.ImageTypeJXL,
+				Quality: 60,
+			},
+			err:         nil,
+			reconstruct: false,
+		},
+		{
+			name: "lossless_PNG_image",
+			give: _TestDataPath + "/" + _TestValidImagePNG,
+			opts: &imgdiet.Options{
+				Format:   imgdiet.ImageTypeJXL,
+				Lossless: true,
+				Effort:   7,
+			},
+			err:         nil,
+			reconstruct: false,
+		},
+		{
+			name: "lossless_JPEG_image",
+			give: _TestDataPath + "/" + _TestValidImageJPG,
+			opts: &imgdiet.Options{
+				Format:   imgdiet.ImageTypeJXL,
+				Lossless: true,
+			},
+			err:         nil,
+			reconstruct: true,
+		},
+		{
+			name: "animated_GIF_image",
+			give: _TestDataPath + "/" + _TestValidImageGIF,
+			opts: &imgdiet.Options{
+				Format: imgdiet.ImageTypeJXL,
+			},
+			err:         imgdiet.ErrAnimatedImage,
+			reconstruct: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			img := openTestImage(t, tt.give)
+			width, height := img.Width(), img.Height()
+
+			optimized, err := img.Optimize(tt.opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			reopened, err := imgdiet.Open(bytes.NewReader(optimized))
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer reopened.Close()
+
+			if reopened.Format() != imgdiet.ImageTypeJXL {
+				t.Fatalf("expected %s image, got %s", imgdiet.ImageTypeJXL, reopened.Format())
+			}
+
+			if reopened.Width() != width || reopened.Height() != height {
+				t.Errorf("expected %dx%d image, got %dx%d", width, height, reopened.Width(), reopened.Height())
+			}
+
+			if got := hasJXLBox(optimized, "jbrd"); got != tt.reconstruct {
+				t.Errorf("expected JPEG reconstruction data %t, got %t", tt.reconstruct, got)
+			}
+
+			if !tt.opts.Lossless || tt.reconstruct {
+				return
+			}
+
+			// Lossless images must decode to the same pixels as the original.
+			original, err := os.ReadFile(tt.give)
+			if err != nil {
+				t.Fatalf("unable to read file: %v", err)
+			}
+
+			decoded, err := reopened.Optimize(&imgdiet.Options{
+				Format:      imgdiet.ImageTypePNG,
+				Compression: 6,
+				Bitdepth:    8,
+			})
+			if err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+
+			want, err := png.Decode(bytes.NewReader(original))
+			if err != nil {
+				t.Fatalf("png.Decode() failed: %v", err)
+			}
+
+			got, err := png.Decode(bytes.NewReader(decoded))
+			if err != nil {
+				t.Fatalf("png.Decode() failed: %v", err)
+			}
+
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					if color.NRGBAModel.Convert(got.At(x, y)) != color.NRGBAModel.Convert(want.At(x, y)) {
+						t.Fatalf("expected pixel %v at %d,%d, got %v", want.At(x, y), x, y, got.At(x, y))
+					}
+				}
+			}
+		})
+	}
+}
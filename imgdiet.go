@@ -7,6 +7,7 @@
 package imgdiet
 
 import (
+	"bytes"
 	"net/http"
 
 	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
@@ -18,6 +19,21 @@ const (
 	ImageTypeJPEG string = "JPEG"
 	ImageTypePNG  string = "PNG"
 	ImageTypeGIF  string = "GIF"
+	ImageTypeWebP string = "WEBP"
+	ImageTypeTIFF string = "TIFF"
+	ImageTypePDF  string = "PDF"
+	ImageTypeSVG  string = "SVG"
+	ImageTypeJXL  string = "JXL"
+)
+
+// List of magic bytes of TIFF and JPEG XL images, which are not detected by
+// http.DetectContentType. JPEG XL images are either a bare codestream or a
+// codestream wrapped in an ISO BMFF based container.
+const (
+	tiffLittleEndian string = "II*\x00"
+	tiffBigEndian    string = "MM\x00*"
+	jxlCodestream    string = "\xff\x0a"
+	jxlContainer     string = "\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a"
 )
 
 // ErrUnsupportedImageFormat is returned when the image format is not supported by this package.
@@ -48,6 +64,14 @@ func Stop() {
 // its magic bytes. It returns a string representation of the image type and an
 // error if the image type is not supported.
 func DetectImageType(image []byte) (string, error) {
+	if bytes.HasPrefix(image, []byte(tiffLittleEndian)) || bytes.HasPrefix(image, []byte(tiffBigEndian)) {
+		return ImageTypeTIFF, nil
+	}
+
+	if bytes.HasPrefix(image, []byte(jxlCodestream)) || bytes.HasPrefix(image, []byte(jxlContainer)) {
+		return ImageTypeJXL, nil
+	}
+
 	switch http.DetectContentType(image) {
 	case "image/jpeg":
 		return ImageTypeJPEG, nil
@@ -55,7 +79,17 @@ func DetectImageType(image []byte) (string, error) {
 		return ImageTypePNG, nil
 	case "image/gif":
 		return ImageTypeGIF, nil
+	case "image/webp":
+		return ImageTypeWebP, nil
+	case "application/pdf":
+		return ImageTypePDF, nil
 	default:
+		// SVG images are detected as XML or plain text, so their root element
+		// is checked instead.
+		if isSVG(image) {
+			return ImageTypeSVG, nil
+		}
+
 		return "", ErrUnsupportedImageFormat
 	}
 }
@@ -63,5 +97,5 @@ func DetectImageType(image []byte) (string, error) {
 // DetectImageSize takes an image as a byte array input and detects the image
 // size in bytes.
 func DetectImageSize(image []byte) int64 {
-	return int64(cap(image))
+	return int64(len(image))
 }
@@ -1,6 +1,7 @@
 package imgdiet_test
 
 import (
+	"errors"
 	"os"
 	"testing"
 
@@ -8,13 +9,26 @@ import (
 )
 
 const (
-	_TestDataPath         string = "testdata"
-	_TestValidImageJPG    string = "james-pond-hotel-chair.jpg"
-	_TestInvalidImageJPG  string = "invalid-image.jpg"
-	_TestValidImagePNG    string = "cipherhost-avatar.png"
-	_TestValidImageGIF    string = "whoops.gif"
-	_TestValidImageWebP   string = "webp-animated.webp"
-	_TestNonExistentImage string = "impossible-girl.jpg"
+	_TestDataPath                     string = "testdata"
+	_TestValidImageJPG                string = "james-pond-hotel-chair.jpg"
+	_TestRotatedImageJPG              string = "exif-orientation.jpg"
+	_TestWideGamutImageJPG            string = "display-p3.jpg"
+	_TestWideGamutProfile             string = "display-p3.icc"
+	_TestIntentsProfile               string = "intents.icc"
+	_TestHighDepthImagePNG            string = "16-bit.png"
+	_TestOpaqueAlphaImagePNG          string = "opaque-alpha.png"
+	_TestTransparentImagePNG          string = "transparent.png"
+	_TestTransparentHighDepthImagePNG string = "transparent-16-bit.png"
+	_TestBorderedImagePNG             string = "white-border.png"
+	_TestInvalidImageJPG              string = "invalid-image.jpg"
+	_TestValidImagePNG                string = "cipherhost-avatar.png"
+	_TestValidImageGIF                string = "whoops.gif"
+	_TestValidImageWebP               string = "webp-animated.webp"
+	_TestMultiPageImageTIFF           string = "multi-page.tif"
+	_TestDocumentPDF                  string = "two-pages.pdf"
+	_TestVectorImageSVG               string = "icon.svg"
+	_TestExternalImageSVG             string = "external-reference.svg"
+	_TestNonExistentImage             string = "impossible-girl.jpg"
 )
 
 func TestMain(m *testing.M) {
@@ -54,8 +68,26 @@ func TestDetectImageType(t *testing.T) {
 		{
 			name: "webp",
 			give: _TestDataPath + "/" + _TestValidImageWebP,
-			want: "",
-			err:  true,
+			want: "WEBP",
+			err:  false,
+		},
+		{
+			name: "tiff",
+			give: _TestDataPath + "/" + _TestMultiPageImageTIFF,
+			want: "TIFF",
+			err:  false,
+		},
+		{
+			name: "pdf",
+			give: _TestDataPath + "/" + _TestDocumentPDF,
+			want: "PDF",
+			err:  false,
+		},
+		{
+			name: "svg",
+			give: _TestDataPath + "/" + _TestVectorImageSVG,
+			want: "SVG",
+			err:  false,
 		},
 		{
 			name: "invalid",
@@ -92,6 +124,47 @@ func TestDetectImageType(t *testing.T) {
 	}
 }
 
+func TestDetectImageType_JXL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give []byte
+		want string
+		err  error
+	}{
+		{
+			name: "codestream",
+			give: []byte("\xff\x0a\xfa\x1f\x42\x09"),
+			want: imgdiet.ImageTypeJXL,
+			err:  nil,
+		},
+		{
+			name: "container",
+			give: []byte("\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a"),
+			want: imgdiet.ImageTypeJXL,
+			err:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imgdiet.DetectImageType(tt.give)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestDetectImageSize(t *testing.T) {
 	t.Parallel()
 
@@ -130,3 +203,14 @@ func TestDetectImageSize(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectImageSize_SpareCapacity(t *testing.T) {
+	t.Parallel()
+
+	image := make([]byte, 0, 4096)
+	image = append(image, "GIF89a"...)
+
+	if got := imgdiet.DetectImageSize(image); got != 6 {
+		t.Fatalf("expected 6, got %d", got)
+	}
+}
@@ -0,0 +1,284 @@
+package imgdiet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+)
+
+// iptcPhotoshopSignature prefixes the Photoshop image resources holding the
+// IPTC block of JPEG images.
+const iptcPhotoshopSignature string = "Photoshop 3.0\x00"
+
+// List of Photoshop image resources relevant to IPTC data.
+const (
+	// iptcResourceIIM is the resource holding the IPTC datasets.
+	iptcResourceIIM uint16 = 0x0404
+
+	// iptcResourceDigest is the resource holding the MD5 digest of the IPTC
+	// datasets, which no longer matches once they are filtered.
+	iptcResourceDigest uint16 = 0x0425
+)
+
+// iptcTag starts every IPTC dataset.
+const iptcTag byte = 0x1c
+
+// filterIPTC returns the given IPTC block without the datasets for which
+// keeps returns false, or nil if no dataset is left. Datasets of the
+// application record are named after the IPTC specification, as in
+// "iptc-By-line" or "iptc-CopyrightNotice", and other ones after their record
+// and dataset numbers, as in "iptc-2-200".
+//
+// The envelope record and the record version are always kept, as they
+// describe how to read the other datasets, and blocks that cannot be parsed
+// are removed entirely, as their datasets cannot be checked.
+//
+// JPEG images store the datasets inside Photoshop image resources, whose
+// other resources are kept unchanged.
+func filterIPTC(block []byte, keeps func(field string) (bool, error)) ([]byte, error) {
+	if !bytes.HasPrefix(block, []byte(iptcPhotoshopSignature)) {
+		return filterIIM(block, keeps)
+	}
+
+	var (
+		out          = append(make([]byte, 0, len(block)), iptcPhotoshopSignature...)
+		offset       = len(iptcPhotoshopSignature)
+		filtered     bool
+		digestHeader []byte
+		digest       []byte
+	)
+
+	// Resources may be followed by padding.
+	for offset < len(block) && block[offset] != 0 {
+		header, resource, next := photoshopResource(block, offset)
+		if next == -1 {
+			return nil, nil
+		}
+
+		offset = next
+
+		switch binary.BigEndian.Uint16(header[4:]) {
+		case iptcResourceIIM:
+			datasets, err := filterIIM(resource, keeps)
+			if err != nil {
+				return nil, err
+			}
+
+			filtered = filtered || !bytes.Equal(datasets, resource)
+
+			if datasets == nil {
+				continue
+			}
+
+			out = appendPhotoshopResource(out, header, datasets)
+		case iptcResourceDigest:
+			digestHeader, digest = header, resource
+		default:
+			out = appendPhotoshopResource(out, header, resource)
+		}
+	}
+
+	if digestHeader != nil && !filtered {
+		out = appendPhotoshopResource(out, digestHeader, digest)
+	}
+
+	if len(out) == len(iptcPhotoshopSignature) {
+		return nil, nil
+	}
+
+	return out, nil
+}
+
+// filterIIM returns the given IPTC datasets without the ones for which keeps
+// returns false, or nil if no dataset of the application record is left, as
+// done by filterIPTC.
+func filterIIM(datasets []byte, keeps func(field string) (bool, error)) ([]byte, error) {
+	var (
+		out    = make([]byte, 0, len(datasets))
+		offset int
+		kept   int
+	)
+
+	// Datasets may be followed by padding.
+	for offset < len(datasets) && datasets[offset] != 0 {
+		if datasets[offset] != iptcTag || offset+5 > len(datasets) {
+			return nil, nil
+		}
+
+		var (
+			record  = datasets[offset+1]
+			dataset = datasets[offset+2]
+			length  = int(binary.BigEndian.Uint16(datasets[offset+3:]))
+			start   = offset + 5
+		)
+
+		// Extended datasets store the size of their length instead, followed
+		// by the length itself.
+		if length&0x8000 != 0 {
+			size := length & 0x7fff
+			if size > 4 || start+size > len(datasets) {
+				return nil, nil
+			}
+
+			length = 0
+
+			for _, b := range datasets[start : start+size] {
+				length = length<<8 | int(b)
+			}
+
+			start += size
+		}
+
+		end := start + length
+		if end > len(datasets) {
+			return nil, nil
+		}
+
+		raw := datasets[offset:end]
+		offset = end
+
+		if record != 2 || dataset == 0 {
+			out = append(out, raw...)
+
+			continue
+		}
+
+		ok, err := keeps(iptcName(record, dataset))
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			out = append(out, raw...)
+			kept++
+		}
+	}
+
+	if kept == 0 {
+		return nil, nil
+	}
+
+	return out, nil
+}
+
+// photoshopResource parses the Photoshop image resource starting at the given
+// offset of block, returning its header, holding its signature, identifier,
+// name, and size, its data, and the offset of the next resource, or -1 if the
+// resource is malformed.
+func photoshopResource(block []byte, offset int) (header, data []byte, next int) {
+	if offset+12 > len(block) || string(block[offset:offset+4]) != "8BIM" {
+		return nil, nil, -1
+	}
+
+	// The name is a Pascal string padded to an even length.
+	start := offset + 6 + (int(block[offset+6])+2)&^1
+	if start+4 > len(block) {
+		return nil, nil, -1
+	}
+
+	var (
+		size = int(binary.BigEndian.Uint32(block[start:]))
+		end  = start + 4 + size
+	)
+
+	if size < 0 || end > len(block) {
+		return nil, nil, -1
+	}
+
+	// The data is padded to an even length as well.
+	return block[offset : start+4], block[start+4 : end], end + size&1
+}
+
+// appendPhotoshopResource appends a Photoshop image resource with the given
+// header, holding its signature, identifier, name, and size, and data to out,
+// updating its size and padding it to an even length.
+func appendPhotoshopResource(out, header, data []byte) []byte {
+	out = append(out, header...)
+	binary.BigEndian.PutUint32(out[len(out)-4:], uint32(len(data)))
+	out = append(out, data...)
+
+	if len(data)&1 != 0 {
+		out = append(out, 0)
+	}
+
+	return out
+}
+
+// iptcName returns the name of the IPTC dataset with the given record and
+// dataset numbers.
+func iptcName(record, dataset byte) string {
+	if record == 2 {
+		if name := iptcApplicationName(dataset); name != "" {
+			return "iptc-" + name
+		}
+	}
+
+	return "iptc-" + strconv.Itoa(int(record)) + "-" + strconv.Itoa(int(dataset))
+}
+
+// iptcApplicationName returns the name of the given dataset of the
+// application record, or an empty string if it is not a common one.
+func iptcApplicationName(dataset byte) string {
+	switch dataset {
+	case 5:
+		return "ObjectName"
+	case 7:
+		return "EditStatus"
+	case 10:
+		return "Urgency"
+	case 12:
+		return "SubjectReference"
+	case 15:
+		return "Category"
+	case 20:
+		return "SupplementalCategories"
+	case 25:
+		return "Keywords"
+	case 40:
+		return "SpecialInstructions"
+	case 55:
+		return "DateCreated"
+	case 60:
+		return "TimeCreated"
+	case 62:
+		return "DigitalCreationDate"
+	case 63:
+		return "DigitalCreationTime"
+	case 65:
+		return "OriginatingProgram"
+	case 70:
+		return "ProgramVersion"
+	case 80:
+		return "By-line"
+	case 85:
+		return "By-lineTitle"
+	case 90:
+		return "City"
+	case 92:
+		return "Sub-location"
+	case 95:
+		return "Province-State"
+	case 100:
+		return "Country-PrimaryLocationCode"
+	case 101:
+		return "Country-PrimaryLocationName"
+	case 103:
+		return "OriginalTransmissionReference"
+	case 105:
+		return "Headline"
+	case 110:
+		return "Credit"
+	case 115:
+		return "Source"
+	case 116:
+		return "CopyrightNotice"
+	case 118:
+		return "Contact"
+	case 120:
+		return "Caption-Abstract"
+	case 122:
+		return "Writer-Editor"
+	default:
+		return ""
+	}
+}
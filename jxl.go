@@ -0,0 +1,226 @@
+package imgdiet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrJPEGReconstruction is returned when a JPEG image cannot be recompressed
+// to a JPEG XL image the original file can be reconstructed from.
+const ErrJPEGReconstruction xerrors.Error = "lossless JPEG to JPEG XL recompression failed"
+
+// List of boxes of the JPEG XL container holding the codestream, either whole
+// or split into parts.
+const (
+	jxlBoxCodestream        string = "jxlc"
+	jxlBoxPartialCodestream string = "jxlp"
+)
+
+// Sizes of the headers of the boxes of the JPEG XL container, which use a
+// 64-bit size field when they are too large for the regular 32-bit one.
+const (
+	jxlBoxHeaderSize         = 8
+	jxlExtendedBoxHeaderSize = 16
+)
+
+// List of JPEG markers of the segments removed from recompressed JPEG images
+// when stripping metadata.
+const (
+	jpegMarkerAPP2  byte = 0xe2
+	jpegMarkerAPP14 byte = 0xee
+	jpegMarkerAPP15 byte = 0xef
+	jpegMarkerCOM   byte = 0xfe
+)
+
+// jpegICCSignature prefixes the ICC profile inside a JPEG APP2 segment.
+const jpegICCSignature string = "ICC_PROFILE\x00"
+
+// jxlBareCodestream returns the given JPEG XL image as a bare codestream,
+// extracting it from the ISO BMFF based container if needed, as govips only
+// decodes bare codestreams.
+//
+// Other boxes of the container are discarded. Exif and XMP metadata is
+// therefore lost, as is the JPEG reconstruction data of recompressed JPEG
+// images, so optimizing an opened JPEG XL image never keeps them.
+func jxlBareCodestream(image []byte) ([]byte, error) {
+	if !bytes.HasPrefix(image, []byte(jxlContainer)) {
+		return image, nil
+	}
+
+	var (
+		codestream []byte
+		offset     int
+	)
+
+	for offset < len(image) {
+		if offset+jxlBoxHeaderSize > len(image) {
+			return nil, fmt.Errorf("%w: truncated JPEG XL box", ErrMalformedImage)
+		}
+
+		var (
+			size   = uint64(binary.BigEndian.Uint32(image[offset:]))
+			kind   = string(image[offset+4 : offset+8])
+			header = uint64(jxlBoxHeaderSize)
+		)
+
+		switch size {
+		case 0:
+			// The last box may extend to the end of the file.
+			size = uint64(len(image) - offset)
+		case 1:
+			if offset+jxlExtendedBoxHeaderSize > len(image) {
+				return nil, fmt.Errorf("%w: truncated JPEG XL box", ErrMalformedImage)
+			}
+
+			size = binary.BigEndian.Uint64(image[offset+8:])
+			header = jxlExtendedBoxHeaderSize
+		}
+
+		if size < header || size > uint64(len(image)-offset) {
+			return nil, fmt.Errorf("%w: invalid JPEG XL box size %d", ErrMalformedImage, size)
+		}
+
+		payload := image[offset+int(header) : offset+int(size)]
+		offset += int(size)
+
+		switch kind {
+		case jxlBoxCodestream:
+			codestream = append(codestream, payload...)
+		case jxlBoxPartialCodestream:
+			// Each part starts with its index, which only matters to decoders
+			// reading the parts as they arrive.
+			if len(payload) < 4 {
+				return nil, fmt.Errorf("%w: truncated JPEG XL box", ErrMalformedImage)
+			}
+
+			codestream = append(codestream, payload[4:]...)
+		}
+	}
+
+	if !bytes.HasPrefix(codestream, []byte(jxlCodestream)) {
+		return nil, fmt.Errorf("%w: JPEG XL container without codestream", ErrMalformedImage)
+	}
+
+	return codestream, nil
+}
+
+// reconstructible reports whether the image can be recompressed to a lossless
+// JPEG XL image the original JPEG file can be reconstructed from, which
+// requires an unedited JPEG image and Options leaving its pixels and metadata
+// untouched, except for stripping the latter. OptimizeICCProfile is ignored,
+// as the profile does not need to be replaced when the pixels are not
+// re-encoded.
+func (i *Image) reconstructible(format string, opts *Options) bool {
+	if format != ImageTypeJXL || !opts.Lossless || i.format != ImageTypeJPEG || i.source == nil {
+		return false
+	}
+
+	// Metadata policies, XMP packets, and color profiles are applied to the
+	// decoded image.
+	if opts.Metadata != nil || i.xmp != nil || opts.ColorProfile != "" {
+		return false
+	}
+
+	return !i.orients(opts)
+}
+
+// recompressJPEG recompresses the JPEG source of the image to a JPEG XL image
+// without decoding it, so the original file, or the file without its metadata
+// if Options.StripMetadata is true, can be reconstructed exactly from it. It is
+// usually about 20% smaller than the original.
+//
+// The output is always a container, holding the reconstruction data and the
+// Exif and XMP metadata in boxes next to the codestream.
+func (i *Image) recompressJPEG(opts *Options) ([]byte, error) {
+	source := i.source
+
+	if opts.StripMetadata {
+		stripped, err := stripJPEGMetadata(source)
+		if err != nil {
+			return nil, err
+		}
+
+		source = stripped
+	}
+
+	effort := int(opts.Effort)
+	if effort < minJXLEffort {
+		effort = minJXLEffort
+	}
+
+	image, err := jxlFromJPEG(source, effort)
+	if err != nil {
+		return nil, err
+	}
+
+	i.saved = DetectImageSize(image)
+
+	return image, nil
+}
+
+// stripJPEGMetadata returns the given JPEG image without its metadata
+// segments, such as Exif, XMP, IPTC, and comments. The JFIF and Adobe
+// segments, which affect how the image is decoded, and the ICC profile are
+// kept.
+func stripJPEGMetadata(image []byte) ([]byte, error) {
+	if len(image) < 2 || image[0] != 0xff || image[1] != jpegMarkerSOI {
+		return nil, fmt.Errorf("%w: missing JPEG start of image", ErrMalformedImage)
+	}
+
+	var (
+		out    = append(make([]byte, 0, len(image)), image[:2]...)
+		offset = 2
+	)
+
+	for {
+		if offset+4 > len(image) || image[offset] != 0xff {
+			return nil, fmt.Errorf("%w: invalid JPEG segment", ErrMalformedImage)
+		}
+
+		marker := image[offset+1]
+		if marker == jpegMarkerSOS {
+			break
+		}
+
+		// The segment length includes its own two bytes, so anything shorter
+		// is invalid.
+		length := int(binary.BigEndian.Uint16(image[offset+2:]))
+		if length < 2 {
+			return nil, fmt.Errorf("%w: invalid JPEG segment length %d", ErrMalformedImage, length)
+		}
+
+		end := offset + 2 + length
+		if end > len(image) {
+			return nil, fmt.Errorf("%w: truncated JPEG segment", ErrMalformedImage)
+		}
+
+		segment := image[offset:end]
+		offset = end
+
+		if jpegMetadataSegment(marker, segment[4:]) {
+			continue
+		}
+
+		out = append(out, segment...)
+	}
+
+	return append(out, image[offset:]...), nil
+}
+
+// jpegMetadataSegment reports whether the JPEG segment with the given marker
+// and payload only holds metadata.
+func jpegMetadataSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == jpegMarkerCOM:
+		return true
+	case marker == jpegMarkerAPP2:
+		return !bytes.HasPrefix(payload, []byte(jpegICCSignature))
+	case marker == jpegMarkerAPP0, marker == jpegMarkerAPP14:
+		return false
+	default:
+		return marker >= jpegMarkerAPP1 && marker <= jpegMarkerAPP15
+	}
+}
@@ -0,0 +1,72 @@
+package imgdiet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestStripJPEGMetadata(t *testing.T) {
+	t.Parallel()
+
+	var (
+		jfif   = "\xff\xe0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"
+		exif   = "\xff\xe1\x00\x0cExif\x00\x00II*\x00"
+		icc    = "\xff\xe2\x00\x10ICC_PROFILE\x00\x01\x01"
+		mpf    = "\xff\xe2\x00\x08MPF\x00\x00\x00"
+		iptc   = "\xff\xed\x00\x10Photoshop 3.0\x00"
+		adobe  = "\xff\xee\x00\x0eAdobe\x00\x64\x00\x00\x00\x00\x01"
+		com    = "\xff\xfe\x00\x07hello"
+		tables = "\xff\xdb\x00\x04\x00\x01"
+		scan   = "\xff\xda\x00\x08\x01\x01\x00\x00\x3f\x00\x12\x34\xff\x00\x56\xff\xd9"
+	)
+
+	tests := []struct {
+		name string
+		give string
+		want string
+		err  error
+	}{
+		{
+			name: "metadata_segments",
+			give: "\xff\xd8" + jfif + exif + icc + mpf + iptc + adobe + com + tables + scan,
+			want: "\xff\xd8" + jfif + icc + adobe + tables + scan,
+		},
+		{
+			name: "no_metadata",
+			give: "\xff\xd8" + jfif + tables + scan,
+			want: "\xff\xd8" + jfif + tables + scan,
+		},
+		{
+			name: "missing_start_of_image",
+			give: jfif + tables + scan,
+			err:  ErrMalformedImage,
+		},
+		{
+			name: "truncated_segment",
+			give: "\xff\xd8" + jfif + exif[:8],
+			err:  ErrMalformedImage,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := stripJPEGMetadata([]byte(tt.give))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !bytes.Equal(got, []byte(tt.want)) {
+				t.Errorf("stripJPEGMetadata() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
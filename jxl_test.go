@@ -0,0 +1,264 @@
+package imgdiet_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+// jxlBox returns a JPEG XL container box of the given type holding the given
+// payload.
+func jxlBox(kind string, payload []byte) []byte {
+	box := make([]byte, 8, 8+len(payload))
+
+	binary.BigEndian.PutUint32(box, uint32(8+len(payload)))
+	copy(box[4:], kind)
+
+	return append(box, payload...)
+}
+
+// hasJXLBox reports whether the given JPEG XL image is a container holding a
+// box of the given type.
+func hasJXLBox(image []byte, kind string) bool {
+	const signature = "\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a"
+
+	if !bytes.HasPrefix(image, []byte(signature)) {
+		return false
+	}
+
+	for offset := 0; offset+8 <= len(image); {
+		if string(image[offset+4:offset+8]) == kind {
+			return true
+		}
+
+		size := int(binary.BigEndian.Uint32(image[offset:]))
+
+		// The last box may extend to the end of the file, and larger boxes use
+		// a 64-bit size field.
+		switch size {
+		case 0:
+			return false
+		case 1:
+			if offset+16 > len(image) {
+				return false
+			}
+
+			size = int(binary.BigEndian.Uint64(image[offset+8:]))
+		}
+
+		if size < 8 {
+			return false
+		}
+
+		offset += size
+	}
+
+	return false
+}
+
+// jxlContainer wraps the given boxes in a JPEG XL container.
+func jxlContainer(boxes ...[]byte) []byte {
+	container := append([]byte("\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a"), jxlBox("ftyp", []byte("jxl \x00\x00\x00\x00jxl "))...)
+
+	for _, box := range boxes {
+		container = append(container, box...)
+	}
+
+	return container
+}
+
+func TestOpen_JXLContainer(t *testing.T) {
+	t.Parallel()
+
+	img := openTestImage(t, _TestDataPath+"/"+_TestValidImagePNG)
+	width, height := img.Width(), img.Height()
+
+	codestream, optimizeErr := img.Optimize(&imgdiet.Options{
+		Format:   imgdiet.ImageTypeJXL,
+		Lossless: true,
+	})
+	if optimizeErr != nil {
+		t.Fatalf("Optimize() failed: %v", optimizeErr)
+	}
+
+	half := len(codestream) / 2
+
+	tests := []struct {
+		name string
+		give []byte
+		err  error
+	}{
+		{
+			name: "codestream_box",
+			give: jxlContainer(
+				jxlBox("jxlc", codestream),
+			),
+			err: nil,
+		},
+		{
+			name: "partial_codestream_boxes",
+			give: jxlContainer(
+				jxlBox("jxlp", append([]byte{0x00, 0x00, 0x00, 0x00}, codestream[:half]...)),
+				jxlBox("Exif", []byte{0x00, 0x00, 0x00, 0x00}),
+				jxlBox("jxlp", append([]byte{0x80, 0x00, 0x00, 0x01}, codestream[half:]...)),
+			),
+			err: nil,
+		},
+		{
+			name: "missing_codestream",
+			give: jxlContainer(),
+			err:  imgdiet.ErrMalformedImage,
+		},
+		{
+			name: "truncated_box",
+			give: jxlContainer(
+				jxlBox("jxlc", codestream)[:half],
+			),
+			err: imgdiet.ErrMalformedImage,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image, err := imgdiet.Open(bytes.NewReader(tt.give))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+			defer image.Close()
+
+			if image.Format() != imgdiet.ImageTypeJXL {
+				t.Fatalf("expected %s image, got %s", imgdiet.ImageTypeJXL, image.Format())
+			}
+
+			if image.Width() != width || image.Height() != height {
+				t.Errorf("expected %dx%d image, got %dx%d", width, height, image.Width(), image.Height())
+			}
+		})
+	}
+}
+
+func TestImage_Optimize_JXLReconstruction(t *testing.T) {
+	t.Parallel()
+
+	original, err := os.ReadFile(_TestDataPath + "/" + _TestValidImageJPG)
+	if err != nil {
+		t.Fatalf("unable to read file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		edit        func(image *imgdiet.Image) error
+		opts        *imgdiet.Options
+		reconstruct bool
+		wantExif    bool
+	}{
+		{
+			name: "unedited_image",
+			edit: nil,
+			opts: &imgdiet.Options{
+				Format:   imgdiet.ImageTypeJXL,
+				Lossless: true,
+				Effort:   7,
+			},
+			reconstruct: true,
+			wantExif:    true,
+		},
+		{
+			name: "strip_metadata",
+			edit: nil,
+			opts: &imgdiet.Options{
+				Format:        imgdiet.ImageTypeJXL,
+				Lossless:      true,
+				StripMetadata: true,
+			},
+			reconstruct: true,
+			wantExif:    false,
+		},
+		{
+			name: "color_profile",
+			edit: nil,
+			opts: &imgdiet.Options{
+				Format:       imgdiet.ImageTypeJXL,
+				Lossless:     true,
+				ColorProfile: imgdiet.ColorProfileSRGB,
+			},
+			reconstruct: false,
+			wantExif:    false,
+		},
+		{
+			name: "edited_image",
+			edit: func(image *imgdiet.Image) error {
+				return image.Flip(imgdiet.FlipHorizontal)
+			},
+			opts: &imgdiet.Options{
+				Format:   imgdiet.ImageTypeJXL,
+				Lossless: true,
+			},
+			reconstruct: false,
+			wantExif:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			img, err := imgdiet.Open(bytes.NewReader(original))
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer img.Close()
+
+			if tt.edit != nil {
+				if err = tt.edit(img); err != nil {
+					t.Fatalf("failed to edit image: %v", err)
+				}
+			}
+
+			got, err := img.Optimize(tt.opts)
+			if err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+
+			if reconstruct := hasJXLBox(got, "jbrd"); reconstruct != tt.reconstruct {
+				t.Fatalf("expected JPEG reconstruction data %t, got %t", tt.reconstruct, reconstruct)
+			}
+
+			// Metadata of recompressed images is stored in boxes of the
+			// container, which must not outlive StripMetadata.
+			if tt.reconstruct {
+				if exif := hasJXLBox(got, "Exif"); exif != tt.wantExif {
+					t.Errorf("expected Exif box %t, got %t", tt.wantExif, exif)
+				}
+
+				if len(got) >= len(original) {
+					t.Errorf("expected recompressed image smaller than %d bytes, got %d", len(original), len(got))
+				}
+			}
+
+			reopened, err := imgdiet.Open(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer reopened.Close()
+
+			if reopened.Width() != img.Width() || reopened.Height() != img.Height() {
+				t.Errorf("expected %dx%d image, got %dx%d", img.Width(), img.Height(), reopened.Width(), reopened.Height())
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+#include "libjxl.h"
+
+static JxlEncoderError imgdiet_jxl_fail(JxlEncoder *encoder, uint8_t *buffer) {
+  JxlEncoderError error = JxlEncoderGetError(encoder);
+
+  if (error == JXL_ENC_ERR_OK) {
+    error = JXL_ENC_ERR_GENERIC;
+  }
+
+  free(buffer);
+  JxlEncoderDestroy(encoder);
+
+  return error;
+}
+
+JxlEncoderError imgdiet_jxl_from_jpeg(const uint8_t *jpeg, size_t length,
+                                      int effort, uint8_t **out,
+                                      size_t *out_length) {
+  JxlEncoder *encoder = JxlEncoderCreate(NULL);
+  JxlEncoderFrameSettings *settings;
+  JxlEncoderStatus status;
+  uint8_t *buffer;
+  uint8_t *next;
+  size_t size = length;
+  size_t available;
+
+  if (encoder == NULL) {
+    return JXL_ENC_ERR_OOM;
+  }
+
+  settings = JxlEncoderFrameSettingsCreate(encoder, NULL);
+
+  // Keeping the JPEG bitstream reconstruction data, stored in a jbrd box of
+  // the container, allows decoders to rebuild the original file.
+  if (JxlEncoderStoreJPEGMetadata(encoder, JXL_TRUE) != JXL_ENC_SUCCESS ||
+      JxlEncoderFrameSettingsSetOption(settings, JXL_ENC_FRAME_SETTING_EFFORT,
+                                       effort) != JXL_ENC_SUCCESS ||
+      JxlEncoderAddJPEGFrame(settings, jpeg, length) != JXL_ENC_SUCCESS) {
+    return imgdiet_jxl_fail(encoder, NULL);
+  }
+
+  JxlEncoderCloseInput(encoder);
+
+  buffer = malloc(size);
+  if (buffer == NULL) {
+    JxlEncoderDestroy(encoder);
+
+    return JXL_ENC_ERR_OOM;
+  }
+
+  next = buffer;
+  available = size;
+
+  while ((status = JxlEncoderProcessOutput(encoder, &next, &available)) ==
+         JXL_ENC_NEED_MORE_OUTPUT) {
+    size_t offset = next - buffer;
+    uint8_t *grown = realloc(buffer, size * 2);
+
+    if (grown == NULL) {
+      free(buffer);
+      JxlEncoderDestroy(encoder);
+
+      return JXL_ENC_ERR_OOM;
+    }
+
+    buffer = grown;
+    size *= 2;
+    next = buffer + offset;
+    available = size - offset;
+  }
+
+  if (status != JXL_ENC_SUCCESS) {
+    return imgdiet_jxl_fail(encoder, buffer);
+  }
+
+  *out = buffer;
+  *out_length = next - buffer;
+
+  JxlEncoderDestroy(encoder);
+
+  return JXL_ENC_ERR_OK;
+}
@@ -0,0 +1,57 @@
+package imgdiet
+
+// #cgo pkg-config: libjxl
+// #include "libjxl.h"
+import "C"
+
+import (
+	"fmt"
+	"strconv"
+	"unsafe"
+)
+
+// jxlFromJPEG recompresses the given JPEG image to a JPEG XL image with the
+// given effort, keeping the data needed to reconstruct the original file
+// byte for byte.
+//
+// libvips only encodes decoded pixels, so libjxl is called directly instead.
+func jxlFromJPEG(jpeg []byte, effort int) ([]byte, error) {
+	if len(jpeg) == 0 {
+		return nil, fmt.Errorf("%w: empty JPEG image", ErrJPEGReconstruction)
+	}
+
+	var (
+		out    *C.uint8_t
+		length C.size_t
+	)
+
+	code := C.imgdiet_jxl_from_jpeg(
+		(*C.uint8_t)(unsafe.Pointer(&jpeg[0])),
+		C.size_t(len(jpeg)),
+		C.int(effort),
+		&out,
+		&length,
+	)
+	if code != C.JXL_ENC_ERR_OK {
+		return nil, fmt.Errorf("%w: %s", ErrJPEGReconstruction, jxlEncoderError(code))
+	}
+	defer C.free(unsafe.Pointer(out))
+
+	return C.GoBytes(unsafe.Pointer(out), C.int(length)), nil
+}
+
+// jxlEncoderError returns a description of the given libjxl encoder error.
+func jxlEncoderError(code C.JxlEncoderError) string {
+	switch code {
+	case C.JXL_ENC_ERR_OOM:
+		return "out of memory"
+	case C.JXL_ENC_ERR_JBRD:
+		return "the JPEG image cannot be reconstructed from JPEG XL"
+	case C.JXL_ENC_ERR_BAD_INPUT:
+		return "invalid JPEG image"
+	case C.JXL_ENC_ERR_NOT_SUPPORTED:
+		return "unsupported JPEG image"
+	default:
+		return "libjxl encoder error " + strconv.Itoa(int(code))
+	}
+}
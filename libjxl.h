@@ -0,0 +1,9 @@
+// Wrappers for the libjxl functions used to recompress JPEG images.
+
+#include <jxl/encode.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+JxlEncoderError imgdiet_jxl_from_jpeg(const uint8_t *jpeg, size_t length,
+                                      int effort, uint8_t **out,
+                                      size_t *out_length);
@@ -0,0 +1,37 @@
+#include "libvips.h"
+
+int imgdiet_set_blob(VipsImage *in, VipsImage **out, const char *name,
+                     const void *data, size_t length) {
+  if (vips_copy(in, out, NULL)) {
+    return -1;
+  }
+
+  vips_image_set_blob_copy(*out, name, data, length);
+
+  return 0;
+}
+
+int imgdiet_icc_transform(VipsImage *in, VipsImage **out,
+                          const char *output_profile,
+                          const char *input_profile, VipsIntent intent,
+                          int depth, gboolean embedded) {
+  return vips_icc_transform(in, out, output_profile, "input_profile",
+                            input_profile, "intent", intent, "depth", depth,
+                            "embedded", embedded, NULL);
+}
+
+int imgdiet_flatten(VipsImage *in, VipsImage **out, double red, double green,
+                    double blue, double max_alpha) {
+  double values[3] = {red, green, blue};
+  VipsArrayDouble *background = vips_array_double_new(values, 3);
+  int result = vips_flatten(in, out, "background", background, "max_alpha",
+                            max_alpha, NULL);
+
+  vips_area_unref(VIPS_AREA(background));
+
+  return result;
+}
+
+int imgdiet_pow(VipsImage *in, VipsImage **out, double exponent) {
+  return vips_math2_const1(in, out, VIPS_OPERATION_MATH2_POW, exponent, NULL);
+}
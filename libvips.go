@@ -0,0 +1,125 @@
+package imgdiet
+
+// #cgo pkg-config: vips
+// #include "libvips.h"
+import "C"
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// ErrLibvips is returned when a libvips operation called directly, instead of
+// through govips, fails.
+const ErrLibvips xerrors.Error = "libvips operation failed"
+
+// transform replaces the libvips image of the given reference with the one
+// returned by fn, releasing the previous one. govips does not export the
+// image, nor a way to wrap one, so both are reached through reflection.
+func transform(ref *vips.ImageRef, fn func(in *C.VipsImage, out **C.VipsImage) C.int) error {
+	var (
+		value = reflect.ValueOf(ref).Elem()
+		lock  = (*sync.Mutex)(unsafe.Pointer(value.FieldByName("lock").UnsafeAddr()))
+		image = (**C.VipsImage)(unsafe.Pointer(value.FieldByName("image").UnsafeAddr()))
+	)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	var out *C.VipsImage
+
+	if fn(*image, &out) != 0 {
+		if out != nil {
+			C.g_object_unref(C.gpointer(out))
+		}
+
+		message := strings.TrimSpace(C.GoString(C.vips_error_buffer()))
+		C.vips_error_clear()
+
+		return fmt.Errorf("%w: %s", ErrLibvips, message)
+	}
+
+	C.g_object_unref(C.gpointer(*image))
+	*image = out
+
+	return nil
+}
+
+// setBlob sets the given metadata field of the image to a copy of data.
+//
+// govips copies the slice header instead of the data when setting blob
+// fields, so libvips is called directly instead.
+func setBlob(ref *vips.ImageRef, field string, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	name := C.CString(field)
+	defer C.free(unsafe.Pointer(name))
+
+	return transform(ref, func(in *C.VipsImage, out **C.VipsImage) C.int {
+		return C.imgdiet_set_blob(in, out, name, unsafe.Pointer(&data[0]), C.size_t(len(data)))
+	})
+}
+
+// iccTransform converts the pixels of the image from their embedded ICC
+// profile, or sRGB if there is none, to the given one with the given
+// rendering intent.
+//
+// govips always uses the perceptual intent, so libvips is called directly
+// instead.
+func iccTransform(ref *vips.ImageRef, profile string, intent vips.Intent) error {
+	var (
+		depth    = 16
+		embedded = C.gboolean(0)
+		format   = ref.BandFormat()
+	)
+
+	if format == vips.BandFormatUchar || format == vips.BandFormatChar || format == vips.BandFormatNotSet {
+		depth = 8
+	}
+
+	if ref.HasICCProfile() {
+		embedded = C.gboolean(1)
+	}
+
+	output := C.CString(profile)
+	defer C.free(unsafe.Pointer(output))
+
+	input := C.CString(vips.SRGBIEC6196621ICCProfilePath)
+	defer C.free(unsafe.Pointer(input))
+
+	return transform(ref, func(in *C.VipsImage, out **C.VipsImage) C.int {
+		return C.imgdiet_icc_transform(in, out, output, input, C.VipsIntent(intent), C.int(depth), embedded)
+	})
+}
+
+// flatten removes the alpha channel of the image, blending it onto the given
+// background color, whose channels, like the alpha channel, range from zero to
+// opaque.
+//
+// govips only scales the background and the alpha range for images
+// interpreted as 16-bit RGB or grayscale, so libvips is called directly
+// instead.
+func flatten(ref *vips.ImageRef, red, green, blue, opaque float64) error {
+	return transform(ref, func(in *C.VipsImage, out **C.VipsImage) C.int {
+		return C.imgdiet_flatten(in, out, C.double(red), C.double(green), C.double(blue), C.double(opaque))
+	})
+}
+
+// power raises each pixel of the image to the given exponent, producing
+// floating-point pixels.
+//
+// govips does not expose the operation, so libvips is called directly
+// instead.
+func power(ref *vips.ImageRef, exponent float64) error {
+	return transform(ref, func(in *C.VipsImage, out **C.VipsImage) C.int {
+		return C.imgdiet_pow(in, out, C.double(exponent))
+	})
+}
@@ -0,0 +1,17 @@
+// Wrappers for the libvips functions not exposed by govips.
+
+#include <stdlib.h>
+#include <vips/vips.h>
+
+int imgdiet_set_blob(VipsImage *in, VipsImage **out, const char *name,
+                     const void *data, size_t length);
+
+int imgdiet_icc_transform(VipsImage *in, VipsImage **out,
+                          const char *output_profile,
+                          const char *input_profile, VipsIntent intent,
+                          int depth, gboolean embedded);
+
+int imgdiet_flatten(VipsImage *in, VipsImage **out, double red, double green,
+                    double blue, double max_alpha);
+
+int imgdiet_pow(VipsImage *in, VipsImage **out, double exponent);
@@ -0,0 +1,606 @@
+package imgdiet
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrInvalidMetadataPattern is returned when a MetadataPolicy contains a
+// malformed pattern.
+const ErrInvalidMetadataPattern xerrors.Error = "invalid metadata pattern"
+
+// List of metadata fields with special handling.
+const (
+	// exifField is the field holding the raw EXIF block of the image.
+	exifField string = "exif-data"
+
+	// exifFieldPrefix is the prefix of every individual EXIF tag field.
+	exifFieldPrefix string = "exif-ifd"
+
+	// iccField is the field holding the ICC profile of the image.
+	iccField string = "icc-profile-data"
+
+	// xmpField is the field holding the XMP packet of the image.
+	xmpField string = "xmp-data"
+
+	// iptcField is the field holding the IPTC block of the image.
+	iptcField string = "iptc-data"
+)
+
+// MetadataPolicy defines which metadata fields are kept in the output image.
+//
+// Fields are identified by their libvips names and matched using the syntax
+// of path.Match. Individual EXIF tags are named after the IFD they belong to,
+// such as "exif-ifd0-Copyright", "exif-ifd2-BodySerialNumber", or
+// "exif-ifd3-GPSLatitude", where IFD 0 describes the main image, IFD 1 its
+// embedded thumbnail, IFD 2 the capture settings, and IFD 3 the GPS location.
+//
+// XMP properties are named after the usual prefix of their namespace, such as
+// "xmp-dc-creator", "xmp-dc-rights", or "xmp-exif-GPSLatitude", and IPTC
+// datasets after the IPTC specification, such as "iptc-By-line",
+// "iptc-CopyrightNotice", or "iptc-City". The XMP packet and the IPTC block as
+// a whole are named "xmp-data" and "iptc-data": denying them removes every
+// property or dataset, and allowing them keeps every property or dataset not
+// denied.
+//
+// The ICC profile is named "icc-profile-data" and can only be kept or
+// removed as a whole.
+type MetadataPolicy struct {
+	// Allow lists the patterns of the fields to keep. If empty, every field
+	// not matched by Deny is kept.
+	Allow []string
+
+	// Deny lists the patterns of the fields to remove. It takes precedence
+	// over Allow.
+	Deny []string
+}
+
+// PrivacyMetadataPolicy returns a MetadataPolicy that removes location data,
+// device identifiers such as serial numbers, and embedded thumbnails, which
+// may show the image before it was cropped or edited, while keeping
+// descriptive fields such as the copyright notice, the artist, and the ICC
+// profile.
+//
+// The same fields are removed from the XMP packet and the IPTC block, along
+// with the XMP document identifiers and editing history, which link the image
+// to the original file.
+func PrivacyMetadataPolicy() *MetadataPolicy {
+	return &MetadataPolicy{
+		Deny: []string{
+			"exif-ifd1-*",
+			"exif-ifd3-*",
+			"exif-ifd0-HostComputer",
+			"exif-ifd2-BodySerialNumber",
+			"exif-ifd2-CameraOwnerName",
+			"exif-ifd2-ImageUniqueID",
+			"exif-ifd2-LensSerialNumber",
+			"exif-ifd2-MakerNote",
+			"jpeg-thumbnail-data",
+			"xmp-exif-GPS*",
+			"xmp-exif-ImageUniqueID",
+			"xmp-exifEX-BodySerialNumber",
+			"xmp-exifEX-CameraOwnerName",
+			"xmp-exifEX-ImageUniqueID",
+			"xmp-exifEX-LensSerialNumber",
+			"xmp-aux-*SerialNumber",
+			"xmp-aux-OwnerName",
+			"xmp-xmp-Thumbnails",
+			"xmp-xmpMM-*",
+			"xmp-crs-RawFileName",
+			"xmp-photoshop-City",
+			"xmp-photoshop-State",
+			"xmp-photoshop-Country",
+			"xmp-Iptc4xmpCore-Location",
+			"xmp-Iptc4xmpCore-CountryCode",
+			"xmp-Iptc4xmpExt-Location*",
+			"iptc-City",
+			"iptc-Sub-location",
+			"iptc-Province-State",
+			"iptc-Country-PrimaryLocation*",
+		},
+	}
+}
+
+// Keeps reports whether the metadata field with the given name is kept by the
+// policy.
+func (p *MetadataPolicy) Keeps(field string) (bool, error) {
+	denied, err := matchAny(p.Deny, field)
+	if err != nil {
+		return false, err
+	}
+
+	if denied {
+		return false, nil
+	}
+
+	if len(p.Allow) == 0 {
+		return true, nil
+	}
+
+	return matchAny(p.Allow, field)
+}
+
+// Validate returns an error if any pattern of the policy is malformed.
+func (p *MetadataPolicy) Validate() error {
+	for _, pattern := range append(append([]string{}, p.Allow...), p.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidMetadataPattern, pattern)
+		}
+	}
+
+	return nil
+}
+
+// clone returns a deep copy of the policy.
+func (p *MetadataPolicy) clone() *MetadataPolicy {
+	if p == nil {
+		return nil
+	}
+
+	return &MetadataPolicy{
+		Allow: append([]string(nil), p.Allow...),
+		Deny:  append([]string(nil), p.Deny...),
+	}
+}
+
+// filterMetadata removes the metadata fields of the image the given Options
+// do not keep, returning the Options to encode the image with. If keepProfile
+// is true, the ICC profile is kept regardless of the Options.
+func (i *Image) filterMetadata(opts *Options, keepProfile bool) (*Options, error) {
+	policy := opts.Metadata
+
+	// Stripping metadata on export would drop the stamped tags, the profile
+	// and the timing of animations as well, so everything else is removed
+	// beforehand instead.
+	if policy == nil && opts.StripMetadata && (len(i.stamped) > 0 || keepProfile || i.Frames() > 1) {
+		policy = &MetadataPolicy{
+			Deny: []string{"*"},
+		}
+	}
+
+	if policy == nil {
+		return opts, nil
+	}
+
+	var always []string
+
+	if keepProfile {
+		always = append(always, iccField)
+	}
+
+	if err := i.applyMetadataPolicy(policy, always...); err != nil {
+		return nil, err
+	}
+
+	// The policy already removed every unwanted field, so the encoder must not
+	// strip the remaining ones.
+	filtered := *opts
+	filtered.StripMetadata = false
+
+	return &filtered, nil
+}
+
+// applyMetadataPolicy removes every metadata field of the image not kept by
+// the given policy, except for the given fields, the timing of animated images
+// and the ones set with SetEXIF.
+func (i *Image) applyMetadataPolicy(policy *MetadataPolicy, always ...string) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	required := make(map[string]struct{}, len(i.stamped)+len(always))
+
+	for field := range i.stamped {
+		required[field] = struct{}{}
+	}
+
+	for _, field := range always {
+		required[field] = struct{}{}
+	}
+
+	for _, field := range animationFields() {
+		required[field] = struct{}{}
+	}
+
+	var (
+		keep  []string
+		blobs = make(map[string][]byte)
+		exif  bool
+		icc   bool
+	)
+
+	for _, field := range i.reference.GetFields() {
+		// The raw EXIF block is handled below, based on the individual tags.
+		if field == exifField {
+			continue
+		}
+
+		kept, err := policy.Keeps(field)
+		if err != nil {
+			return err
+		}
+
+		_, always := required[field]
+
+		switch {
+		case always:
+		case field == xmpField || field == iptcField:
+			data, filterErr := i.filterBlob(field, policy, kept)
+			if filterErr != nil {
+				return filterErr
+			}
+
+			if data == nil {
+				continue
+			}
+
+			blobs[field] = data
+		case !kept:
+			continue
+		}
+
+		keep = append(keep, field)
+
+		if strings.HasPrefix(field, exifFieldPrefix) {
+			exif = true
+		}
+
+		if field == iccField {
+			icc = true
+		}
+	}
+
+	// libvips rebuilds the EXIF block from the individual tags when encoding,
+	// dropping the ones that were removed, so the block is needed only if at
+	// least one tag survives and it was not explicitly denied.
+	if exif {
+		denied, err := matchAny(policy.Deny, exifField)
+		if err != nil {
+			return err
+		}
+
+		if !denied {
+			keep = append(keep, exifField)
+		}
+	}
+
+	if err := i.reference.RemoveMetadata(keep...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	for field, data := range blobs {
+		if err := setBlob(i.reference, field, data); err != nil {
+			return err
+		}
+	}
+
+	// RemoveMetadata always keeps the ICC profile, so it has to be removed
+	// separately.
+	if !icc && i.reference.HasICCProfile() {
+		if err := i.reference.RemoveICCProfile(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+// filterBlob returns the XMP packet or IPTC block stored in the given field
+// without the properties or datasets the given policy does not keep, or nil if
+// none is left. If allowed is true, the policy keeps the whole field, so only
+// the denied properties and datasets are removed.
+func (i *Image) filterBlob(field string, policy *MetadataPolicy, allowed bool) ([]byte, error) {
+	denied, err := matchAny(policy.Deny, field)
+	if err != nil || denied {
+		return nil, err
+	}
+
+	data := i.blob(field)
+
+	// The packet set with SetXMP replaces this one when encoding.
+	if field == xmpField && i.xmp != nil {
+		return data, nil
+	}
+
+	keeps := policy.Keeps
+
+	if allowed {
+		keeps = func(name string) (bool, error) {
+			removed, matchErr := matchAny(policy.Deny, name)
+
+			return !removed, matchErr
+		}
+	}
+
+	if field == xmpField {
+		return filterXMP(data, keeps)
+	}
+
+	return filterIPTC(data, keeps)
+}
+
+// matchAny reports whether name matches any of the given patterns.
+func matchAny(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("%w: %s", ErrInvalidMetadataPattern, pattern)
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Metadata holds the descriptive metadata of an image, as read from its EXIF
+// tags and ICC profile, along with the raw metadata blobs.
+type Metadata struct {
+	// GPS is the location the image was captured at, or nil if unknown.
+	GPS *GPS
+
+	// Tags holds every EXIF tag of the image keyed by its libvips name, such
+	// as "exif-ifd0-Make", with the libvips type annotation removed.
+	Tags map[string]string
+
+	// DateTime is the time the image was captured, or the zero time if
+	// unknown.
+	DateTime time.Time
+
+	// Make is the manufacturer of the camera.
+	Make string
+
+	// Model is the model of the camera.
+	Model string
+
+	// Software is the software used to create or edit the image.
+	Software string
+
+	// Artist is the creator of the image.
+	Artist string
+
+	// Copyright is the copyright notice of the image.
+	Copyright string
+
+	// ICCDescription is the description of the embedded ICC profile, such as
+	// "sRGB IEC61966-2.1".
+	ICCDescription string
+
+	// ICCProfile is the embedded ICC profile, or nil if the image has none.
+	ICCProfile []byte
+
+	// EXIF is the raw EXIF block, or nil if the image has none.
+	EXIF []byte
+
+	// XMP is the raw XMP packet, or nil if the image has none. It is the
+	// packet given to SetXMP or Stamp, if any.
+	XMP []byte
+
+	// IPTC is the raw IPTC block, or nil if the image has none.
+	IPTC []byte
+
+	// Orientation is the EXIF orientation of the image, between 1 and 8, or 0
+	// if unknown.
+	Orientation int
+}
+
+// GPS is a location in decimal degrees, as defined by WGS 84.
+type GPS struct {
+	// Latitude is positive north of the equator and negative south of it.
+	Latitude float64
+
+	// Longitude is positive east of the prime meridian and negative west of
+	// it.
+	Longitude float64
+
+	// Altitude is the altitude in meters, negative below sea level.
+	Altitude float64
+}
+
+// Metadata returns the metadata of the image. Orientation reflects the
+// current state of the image, so it is 0 after AutoOrient applies the tag.
+func (i *Image) Metadata() *Metadata {
+	meta := &Metadata{
+		Tags:        make(map[string]string),
+		ICCProfile:  i.blob(iccField),
+		EXIF:        i.blob(exifField),
+		XMP:         bytes.Clone(i.xmp),
+		IPTC:        i.blob(iptcField),
+		Orientation: i.reference.GetOrientation(),
+	}
+
+	if meta.XMP == nil {
+		meta.XMP = i.blob(xmpField)
+	}
+
+	for _, field := range i.reference.GetFields() {
+		if strings.HasPrefix(field, exifFieldPrefix) {
+			meta.Tags[field] = exifValue(i.reference.GetString(field))
+		}
+	}
+
+	meta.Make = meta.Tags["exif-ifd0-Make"]
+	meta.Model = meta.Tags["exif-ifd0-Model"]
+	meta.Software = meta.Tags["exif-ifd0-Software"]
+	meta.Artist = meta.Tags["exif-ifd0-Artist"]
+	meta.Copyright = meta.Tags["exif-ifd0-Copyright"]
+	meta.DateTime = exifTime(meta.Tags)
+	meta.GPS = exifGPS(meta.Tags)
+
+	if meta.ICCProfile != nil {
+		meta.ICCDescription = iccDescription(meta.ICCProfile)
+	}
+
+	return meta
+}
+
+// blob returns a copy of the blob stored in the given field of the image, or
+// nil if the field is empty or missing.
+func (i *Image) blob(field string) []byte {
+	data := i.reference.GetBlob(field)
+	if len(data) == 0 {
+		return nil
+	}
+
+	return data
+}
+
+// exifValue returns the value of an EXIF tag as formatted by libvips, without
+// the annotation libvips appends to it, as in "X-T5 (X-T5, ASCII, 5
+// components, 5 bytes)".
+func exifValue(s string) string {
+	end := strings.LastIndex(s, ", ")
+	if end == -1 || !strings.HasSuffix(s, " bytes)") {
+		return s
+	}
+
+	// The annotation repeats the value in human-readable form, which may
+	// itself contain parentheses, so prefer the split where both halves
+	// agree.
+	for start := strings.Index(s, " ("); start != -1 && start < end; {
+		if strings.HasPrefix(s[start+2:], s[:start]+", ") {
+			return s[:start]
+		}
+
+		next := strings.Index(s[start+2:], " (")
+		if next == -1 {
+			break
+		}
+
+		start += next + 2
+	}
+
+	if start := strings.LastIndex(s[:end], " ("); start != -1 {
+		return s[:start]
+	}
+
+	return s
+}
+
+// exifTime returns the capture time found in the given EXIF tags, or the
+// zero time if there is none.
+func exifTime(tags map[string]string) time.Time {
+	const layout = "2006:01:02 15:04:05"
+
+	for _, names := range [][2]string{
+		{"exif-ifd2-DateTimeOriginal", "exif-ifd2-OffsetTimeOriginal"},
+		{"exif-ifd2-DateTimeDigitized", "exif-ifd2-OffsetTimeDigitized"},
+		{"exif-ifd0-DateTime", "exif-ifd2-OffsetTime"},
+	} {
+		value := strings.TrimSpace(tags[names[0]])
+		if value == "" {
+			continue
+		}
+
+		if offset := strings.TrimSpace(tags[names[1]]); offset != "" {
+			if t, err := time.Parse(layout+"-07:00", value+offset); err == nil {
+				return t
+			}
+		}
+
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// exifGPS returns the location found in the given EXIF tags, or nil if there
+// is none.
+func exifGPS(tags map[string]string) *GPS {
+	latitude, latOK := exifDegrees(tags["exif-ifd3-GPSLatitude"])
+	longitude, lonOK := exifDegrees(tags["exif-ifd3-GPSLongitude"])
+
+	if !latOK || !lonOK {
+		return nil
+	}
+
+	if strings.EqualFold(strings.TrimSpace(tags["exif-ifd3-GPSLatitudeRef"]), "S") {
+		latitude = -latitude
+	}
+
+	if strings.EqualFold(strings.TrimSpace(tags["exif-ifd3-GPSLongitudeRef"]), "W") {
+		longitude = -longitude
+	}
+
+	gps := &GPS{
+		Latitude:  latitude,
+		Longitude: longitude,
+	}
+
+	if altitude, ok := exifRationals(tags["exif-ifd3-GPSAltitude"]); ok && len(altitude) > 0 {
+		gps.Altitude = altitude[0]
+
+		ref := strings.ToLower(strings.TrimSpace(tags["exif-ifd3-GPSAltitudeRef"]))
+		if ref == "1" || strings.Contains(ref, "below") {
+			gps.Altitude = -gps.Altitude
+		}
+	}
+
+	return gps
+}
+
+// exifDegrees converts an EXIF degrees, minutes, and seconds value into
+// decimal degrees.
+func exifDegrees(s string) (float64, bool) {
+	values, ok := exifRationals(s)
+	if !ok || len(values) == 0 {
+		return 0, false
+	}
+
+	var (
+		degrees float64
+		divisor float64 = 1
+	)
+
+	for _, value := range values {
+		degrees += value / divisor
+		divisor *= 60
+	}
+
+	return degrees, true
+}
+
+// exifRationals parses an EXIF value holding one or more rationals, such as
+// "48/1 51/1 2970/100".
+func exifRationals(s string) ([]float64, bool) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	values := make([]float64, 0, len(fields))
+
+	for _, field := range fields {
+		numerator, denominator, found := strings.Cut(field, "/")
+
+		n, err := strconv.ParseFloat(numerator, 64)
+		if err != nil {
+			return nil, false
+		}
+
+		d := 1.0
+
+		if found {
+			d, err = strconv.ParseFloat(denominator, 64)
+			if err != nil || d == 0 {
+				return nil, false
+			}
+		}
+
+		values = append(values, n/d)
+	}
+
+	return values, true
+}
@@ -0,0 +1,213 @@
+package imgdiet
+
+import (
+	"bytes"
+	"path"
+	"testing"
+)
+
+// denyKeeps returns a function keeping every field not matched by the given
+// patterns.
+func denyKeeps(patterns ...string) func(field string) (bool, error) {
+	return func(field string) (bool, error) {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, field); ok { //nolint:errcheck // patterns are valid
+				return false, nil
+			}
+		}
+
+		return true, nil
+	}
+}
+
+func TestFilterXMP(t *testing.T) {
+	t.Parallel()
+
+	const packet = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:ex="http://ns.adobe.com/exif/1.0/"
+    xmlns:aux="http://ns.adobe.com/exif/1.0/aux/"
+   ex:GPSLatitude="48,51.4950N"
+   aux:SerialNumber="3AA17275"
+   dc:format="image/jpeg">
+   <dc:creator><rdf:Seq><rdf:li>Jane &amp; John Doe</rdf:li></rdf:Seq></dc:creator>
+   <ex:GPSLongitude>2,17.4050E</ex:GPSLongitude>
+   <dc:rights><rdf:Alt><rdf:li xml:lang="x-default">All rights reserved</rdf:li></rdf:Alt></dc:rights>
+  </rdf:Description>
+  <rdf:Description rdf:about="" xmlns:xmpMM="http://ns.adobe.com/xap/1.0/mm/"
+   xmpMM:DocumentID="xmp.did:4f4afbf1"/>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+	tests := []struct {
+		name    string
+		give    string
+		keeps   func(field string) (bool, error)
+		want    []string
+		notWant []string
+		wantNil bool
+	}{
+		{
+			name:  "keep_everything",
+			give:  packet,
+			keeps: denyKeeps(),
+			want: []string{
+				`ex:GPSLatitude="48,51.4950N"`,
+				`aux:SerialNumber="3AA17275"`,
+				"<ex:GPSLongitude>",
+				"Jane &amp; John Doe",
+				`xmpMM:DocumentID="xmp.did:4f4afbf1"/>`,
+				`<?xpacket end="w"?>`,
+			},
+		},
+		{
+			name:  "remove_location_and_identifiers",
+			give:  packet,
+			keeps: denyKeeps("xmp-exif-GPS*", "xmp-aux-SerialNumber", "xmp-xmpMM-*"),
+			want: []string{
+				`dc:format="image/jpeg">`,
+				"<dc:creator><rdf:Seq><rdf:li>Jane &amp; John Doe</rdf:li></rdf:Seq></dc:creator>",
+				"<dc:rights>",
+				`<rdf:Description rdf:about="" xmlns:xmpMM="http://ns.adobe.com/xap/1.0/mm/"/>`,
+				"</rdf:RDF>",
+			},
+			notWant: []string{"GPS", "3AA17275", "xmp.did"},
+		},
+		{
+			name:    "remove_every_property",
+			give:    packet,
+			keeps:   denyKeeps("xmp-*"),
+			wantNil: true,
+		},
+		{
+			name:    "malformed_packet",
+			give:    `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF`,
+			keeps:   denyKeeps(),
+			wantNil: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := filterXMP([]byte(tt.give), tt.keeps)
+			if err != nil {
+				t.Fatalf("filterXMP() failed: %v", err)
+			}
+
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("filterXMP() = %q, want nil %t", got, tt.wantNil)
+			}
+
+			for _, want := range tt.want {
+				if !bytes.Contains(got, []byte(want)) {
+					t.Errorf("expected packet to contain %q, got %q", want, got)
+				}
+			}
+
+			for _, notWant := range tt.notWant {
+				if bytes.Contains(got, []byte(notWant)) {
+					t.Errorf("expected packet not to contain %q, got %q", notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterIPTC(t *testing.T) {
+	t.Parallel()
+
+	var (
+		datasets = []byte("\x1c\x01\x5a\x00\x03\x1b\x25\x47" + // CodedCharacterSet
+			"\x1c\x02\x00\x00\x02\x00\x04" + // RecordVersion
+			"\x1c\x02\x50\x00\x08Jane Doe" + // By-line
+			"\x1c\x02\x5a\x00\x05Paris" + // City
+			"\x1c\x02\x74\x00\x13All rights reserved") // CopyrightNotice
+		withoutCity = []byte("\x1c\x01\x5a\x00\x03\x1b\x25\x47" +
+			"\x1c\x02\x00\x00\x02\x00\x04" +
+			"\x1c\x02\x50\x00\x08Jane Doe" +
+			"\x1c\x02\x74\x00\x13All rights reserved")
+		digest = []byte("8BIM\x04\x25\x00\x00\x00\x00\x00\x100123456789abcdef")
+	)
+
+	// photoshop wraps the given datasets in Photoshop image resources,
+	// preceded by a resolution resource and followed by the given resources.
+	photoshop := func(datasets []byte, resources ...[]byte) []byte {
+		block := []byte(iptcPhotoshopSignature + "8BIM\x03\xed\x00\x00\x00\x00\x00\x10\x00\x48\x00\x00\x00\x01\x00\x01\x00\x48\x00\x00\x00\x01\x00\x01")
+		block = appendPhotoshopResource(block, []byte("8BIM\x04\x04\x00\x00\x00\x00\x00\x00"), datasets)
+
+		for _, resource := range resources {
+			block = append(block, resource...)
+		}
+
+		return block
+	}
+
+	tests := []struct {
+		name  string
+		give  []byte
+		keeps func(field string) (bool, error)
+		want  []byte
+	}{
+		{
+			name:  "keep_everything",
+			give:  datasets,
+			keeps: denyKeeps(),
+			want:  datasets,
+		},
+		{
+			name:  "remove_location",
+			give:  datasets,
+			keeps: denyKeeps("iptc-City"),
+			want:  withoutCity,
+		},
+		{
+			name:  "remove_every_dataset",
+			give:  datasets,
+			keeps: denyKeeps("iptc-*"),
+			want:  nil,
+		},
+		{
+			name:  "photoshop_resources",
+			give:  photoshop(datasets, digest),
+			keeps: denyKeeps("iptc-City"),
+			want:  photoshop(withoutCity),
+		},
+		{
+			name:  "unchanged_photoshop_resources",
+			give:  photoshop(datasets, digest),
+			keeps: denyKeeps("iptc-Keywords"),
+			want:  photoshop(datasets, digest),
+		},
+		{
+			name:  "truncated_dataset",
+			give:  datasets[:len(datasets)-4],
+			keeps: denyKeeps(),
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := filterIPTC(tt.give, tt.keeps)
+			if err != nil {
+				t.Fatalf("filterIPTC() failed: %v", err)
+			}
+
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("filterIPTC() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
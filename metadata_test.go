@@ -0,0 +1,358 @@
+package imgdiet_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+func TestMetadataPolicy_Keeps(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		policy *imgdiet.MetadataPolicy
+		give   string
+		want   bool
+		err    error
+	}{
+		{
+			name:   "empty_policy_keeps_everything",
+			policy: &imgdiet.MetadataPolicy{},
+			give:   "exif-ifd3-GPSLatitude",
+			want:   true,
+		},
+		{
+			name:   "allowed_field",
+			policy: &imgdiet.MetadataPolicy{Allow: []string{"exif-ifd0-*"}},
+			give:   "exif-ifd0-Copyright",
+			want:   true,
+		},
+		{
+			name:   "field_not_allowed",
+			policy: &imgdiet.MetadataPolicy{Allow: []string{"exif-ifd0-*"}},
+			give:   "xmp-data",
+			want:   false,
+		},
+		{
+			name: "deny_takes_precedence",
+			policy: &imgdiet.MetadataPolicy{
+				Allow: []string{"exif-ifd0-*"},
+				Deny:  []string{"exif-ifd0-HostComputer"},
+			},
+			give: "exif-ifd0-HostComputer",
+			want: false,
+		},
+		{
+			name:   "privacy_removes_location",
+			policy: imgdiet.PrivacyMetadataPolicy(),
+			give:   "exif-ifd3-GPSLongitude",
+			want:   false,
+		},
+		{
+			name:   "privacy_removes_serial_numbers",
+			policy: imgdiet.PrivacyMetadataPolicy(),
+			give:   "exif-ifd2-BodySerialNumber",
+			want:   false,
+		},
+		{
+			name:   "privacy_keeps_copyright",
+			policy: imgdiet.PrivacyMetadataPolicy(),
+			give:   "exif-ifd0-Copyright",
+			want:   true,
+		},
+		{
+			name:   "privacy_removes_xmp_location",
+			policy: imgdiet.PrivacyMetadataPolicy(),
+			give:   "xmp-exif-GPSLatitude",
+			want:   false,
+		},
+		{
+			name:   "privacy_keeps_xmp_creator",
+			policy: imgdiet.PrivacyMetadataPolicy(),
+			give:   "xmp-dc-creator",
+			want:   true,
+		},
+		{
+			name:   "privacy_removes_iptc_location",
+			policy: imgdiet.PrivacyMetadataPolicy(),
+			give:   "iptc-City",
+			want:   false,
+		},
+		{
+			name:   "privacy_keeps_iptc_copyright",
+			policy: imgdiet.PrivacyMetadataPolicy(),
+			give:   "iptc-CopyrightNotice",
+			want:   true,
+		},
+		{
+			name:   "privacy_keeps_icc_profile",
+			policy: imgdiet.PrivacyMetadataPolicy(),
+			give:   "icc-profile-data",
+			want:   true,
+		},
+		{
+			name:   "invalid_pattern",
+			policy: &imgdiet.MetadataPolicy{Deny: []string{"exif-["}},
+			give:   "exif-data",
+			want:   false,
+			err:    imgdiet.ErrInvalidMetadataPattern,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.policy.Keeps(tt.give)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("expected %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestImage_Optimize_MetadataPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		policy  *imgdiet.MetadataPolicy
+		want    []string
+		notWant []string
+		err     error
+	}{
+		{
+			name:    "privacy",
+			policy:  imgdiet.PrivacyMetadataPolicy(),
+			want:    []string{"FUJIFILM", "X-T5", "xmp:CreatorTool", "Photoshop 3.0"},
+			notWant: []string{"3AA17275", "xmpMM:DocumentID"},
+		},
+		{
+			name:    "allow_xmp_fields",
+			policy:  &imgdiet.MetadataPolicy{Allow: []string{"xmp-xmp-*", "iptc-DateCreated"}},
+			want:    []string{"xmp:CreatorTool", "xmp:ModifyDate", "20230524"},
+			notWant: []string{"FUJIFILM", "crs:", "aux:", "082152-0300"},
+		},
+		{
+			name:    "deny_xmp_packet",
+			policy:  &imgdiet.MetadataPolicy{Deny: []string{"xmp-data"}},
+			want:    []string{"FUJIFILM"},
+			notWant: []string{"xmp:CreatorTool"},
+		},
+		{
+			name:    "allow_camera_model_only",
+			policy:  &imgdiet.MetadataPolicy{Allow: []string{"exif-ifd0-Model"}},
+			want:    []string{"X-T5"},
+			notWant: []string{"FUJIFILM", "Lightroom"},
+		},
+		{
+			name:    "deny_everything",
+			policy:  &imgdiet.MetadataPolicy{Deny: []string{"*"}},
+			notWant: []string{"Exif", "FUJIFILM"},
+		},
+		{
+			name:   "invalid_pattern",
+			policy: &imgdiet.MetadataPolicy{Allow: []string{"["}},
+			err:    imgdiet.ErrInvalidMetadataPattern,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(_TestDataPath + "/" + _TestValidImageJPG)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			image, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer image.Close()
+
+			opts := imgdiet.DefaultOptions()
+			opts.Metadata = tt.policy
+
+			got, err := image.Optimize(opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			for _, want := range tt.want {
+				if !bytes.Contains(got, []byte(want)) {
+					t.Errorf("expected output to contain %q", want)
+				}
+			}
+
+			for _, notWant := range tt.notWant {
+				if bytes.Contains(got, []byte(notWant)) {
+					t.Errorf("expected output not to contain %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestImage_Optimize_MetadataPolicy_XMP(t *testing.T) {
+	t.Parallel()
+
+	const packet = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+   exif:GPSLatitude="48,51.4950N"
+   exif:GPSLongitude="2,17.4050E">
+   <dc:creator><rdf:Seq><rdf:li>Jane Doe</rdf:li></rdf:Seq></dc:creator>
+   <dc:rights><rdf:Alt><rdf:li xml:lang="x-default">All rights reserved</rdf:li></rdf:Alt></dc:rights>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+	source := openTestImage(t, _TestDataPath+"/"+_TestValidImagePNG)
+	source.SetXMP([]byte(packet))
+
+	data, err := source.Optimize(&imgdiet.Options{Format: imgdiet.ImageTypeJPEG, Quality: 80})
+	if err != nil {
+		t.Fatalf("Optimize() failed: %v", err)
+	}
+
+	image, err := imgdiet.Open(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer image.Close()
+
+	opts := imgdiet.DefaultOptions()
+	opts.Metadata = imgdiet.PrivacyMetadataPolicy()
+
+	got, err := image.Optimize(opts)
+	if err != nil {
+		t.Fatalf("Optimize() failed: %v", err)
+	}
+
+	for _, want := range []string{"Jane Doe", "All rights reserved"} {
+		if !bytes.Contains(got, []byte(want)) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+
+	if bytes.Contains(got, []byte("GPS")) {
+		t.Error("expected output not to contain the XMP location")
+	}
+}
+
+func TestImage_Metadata_XMPCopy(t *testing.T) {
+	t.Parallel()
+
+	image := openTestImage(t, _TestDataPath+"/"+_TestValidImagePNG)
+	image.SetXMP([]byte("<x:xmpmeta/>"))
+
+	image.Metadata().XMP[0] = 'X'
+
+	if got := string(image.Metadata().XMP); got != "<x:xmpmeta/>" {
+		t.Errorf("expected XMP %q, got %q", "<x:xmpmeta/>", got)
+	}
+}
+
+func TestImage_Metadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		give            string
+		wantMake        string
+		wantModel       string
+		wantICC         string
+		wantDateTime    time.Time
+		wantOrientation int
+		wantXMP         bool
+	}{
+		{
+			name:         "valid_JPEG_image",
+			give:         _TestDataPath + "/" + _TestValidImageJPG,
+			wantMake:     "FUJIFILM",
+			wantModel:    "X-T5",
+			wantICC:      "sRGB IEC61966-2.1",
+			wantDateTime: time.Date(2023, time.May, 24, 8, 21, 52, 0, time.FixedZone("", -3*60*60)),
+			wantXMP:      true,
+		},
+		{
+			name:            "rotated_JPEG_image",
+			give:            _TestDataPath + "/" + _TestRotatedImageJPG,
+			wantOrientation: 6,
+		},
+		{
+			name: "valid_PNG_image",
+			give: _TestDataPath + "/" + _TestValidImagePNG,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(tt.give)
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			image, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer image.Close()
+
+			got := image.Metadata()
+
+			if got.Make != tt.wantMake {
+				t.Errorf("expected make %q, got %q", tt.wantMake, got.Make)
+			}
+
+			if got.Model != tt.wantModel {
+				t.Errorf("expected model %q, got %q", tt.wantModel, got.Model)
+			}
+
+			if got.ICCDescription != tt.wantICC {
+				t.Errorf("expected ICC description %q, got %q", tt.wantICC, got.ICCDescription)
+			}
+
+			if !got.DateTime.Equal(tt.wantDateTime) {
+				t.Errorf("expected date and time %v, got %v", tt.wantDateTime, got.DateTime)
+			}
+
+			if tt.wantOrientation != 0 && got.Orientation != tt.wantOrientation {
+				t.Errorf("expected orientation %d, got %d", tt.wantOrientation, got.Orientation)
+			}
+
+			if (got.XMP != nil) != tt.wantXMP {
+				t.Errorf("expected XMP %t, got %d bytes", tt.wantXMP, len(got.XMP))
+			}
+
+			if got.GPS != nil {
+				t.Errorf("expected no GPS data, got %+v", got.GPS)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package imgdiet
+
+import (
+	"fmt"
+	"math"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+const (
+	// ErrInvalidOpacity is returned when the opacity of an overlay is out of
+	// range.
+	ErrInvalidOpacity xerrors.Error = "opacity must be greater than 0 and at most 1"
+
+	// ErrInvalidScale is returned when the scale of an overlay is negative.
+	ErrInvalidScale xerrors.Error = "scale must not be negative"
+)
+
+// Gravity defines the edge or corner of an image an overlay is placed
+// against.
+type Gravity int
+
+// List of gravities supported by Overlay.
+const (
+	GravityCenter Gravity = iota
+	GravityNorth
+	GravityNorthEast
+	GravityEast
+	GravitySouthEast
+	GravitySouth
+	GravitySouthWest
+	GravityWest
+	GravityNorthWest
+)
+
+// OverlayOptions represents the parameters used to composite an overlay onto
+// an image.
+type OverlayOptions struct {
+	// Gravity defines the edge or corner of the image the overlay is placed
+	// against.
+	Gravity Gravity
+
+	// X is the horizontal distance in pixels between the overlay and the edge
+	// given by Gravity, or the horizontal gap between tiles if Tile is true.
+	X int
+
+	// Y is the vertical distance in pixels between the overlay and the edge
+	// given by Gravity, or the vertical gap between tiles if Tile is true.
+	Y int
+
+	// Scale defines the width of the overlay as a fraction of the width of the
+	// image, such as 0.2 for a fifth of it. If zero, the overlay keeps its
+	// size.
+	Scale float64
+
+	// Opacity defines the opacity of the overlay. It is a number greater than
+	// 0 and at most 1, where 1 is fully opaque.
+	Opacity float64
+
+	// Tile defines whether the overlay is repeated across the whole image,
+	// starting from its top-left corner, as done for watermarks protecting
+	// preview images.
+	Tile bool
+}
+
+// DefaultOverlayOptions returns a set of defaults for placing a logo in the
+// bottom-right corner of an image.
+func DefaultOverlayOptions() *OverlayOptions {
+	return &OverlayOptions{
+		Gravity: GravitySouthEast,
+		X:       16,
+		Y:       16,
+		Opacity: 1,
+	}
+}
+
+// Overlay composites the given image onto the image according to the given
+// OverlayOptions, or DefaultOverlayOptions if nil. The overlay is placed
+// relative to the image as it is displayed, applying its EXIF orientation
+// first. The overlay image is left untouched, so it can be reused across
+// images. Animated images and overlays are not supported.
+func (i *Image) Overlay(overlay *Image, opts *OverlayOptions) error {
+	if opts == nil {
+		opts = DefaultOverlayOptions()
+	}
+
+	if err := validateOverlay(overlay, opts); err != nil {
+		return err
+	}
+
+	if err := i.still(); err != nil {
+		return err
+	}
+
+	if err := i.upright(); err != nil {
+		return err
+	}
+
+	layer, err := overlay.reference.Copy()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer layer.Close()
+
+	if opts.Scale > 0 {
+		scale := opts.Scale * float64(i.reference.Width()) / float64(layer.Width())
+
+		if err = layer.Resize(scale, vips.KernelLanczos3); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err = layer.AddAlpha(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if opts.Opacity < 1 {
+		if err = fade(layer, opts.Opacity); err != nil {
+			return err
+		}
+	}
+
+	var x, y int
+
+	if opts.Tile {
+		if err = tile(layer, opts.X, opts.Y, i.reference.Width(), i.reference.Height()); err != nil {
+			return err
+		}
+	} else {
+		x, y = position(
+			opts.Gravity,
+			opts.X,
+			opts.Y,
+			i.reference.Width(),
+			i.reference.Height(),
+			layer.Width(),
+			layer.Height(),
+		)
+	}
+
+	i.modified()
+
+	if err = i.reference.Composite(layer, vips.BlendModeOver, x, y); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// validateOverlay returns an error if the given overlay image or
+// OverlayOptions cannot be composited.
+func validateOverlay(overlay *Image, opts *OverlayOptions) error {
+	if overlay == nil {
+		return fmt.Errorf("%w", ErrNilImage)
+	}
+
+	if opts.Opacity <= 0 || opts.Opacity > 1 {
+		return fmt.Errorf("%w: %v", ErrInvalidOpacity, opts.Opacity)
+	}
+
+	if opts.Scale < 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidScale, opts.Scale)
+	}
+
+	return overlay.still()
+}
+
+// fade multiplies the alpha channel of the given image by opacity.
+func fade(layer *vips.ImageRef, opacity float64) error {
+	var (
+		format = layer.BandFormat()
+		bands  = layer.Bands()
+		a      = make([]float64, 0, bands)
+		b      = make([]float64, 0, bands)
+	)
+
+	// Leave the color bands untouched and scale the alpha band, which is
+	// always the last one.
+	for n := 0; n < bands; n++ {
+		if n == bands-1 {
+			a = append(a, opacity)
+		} else {
+			a = append(a, 1)
+		}
+
+		b = append(b, 0)
+	}
+
+	if err := layer.Linear(a, b); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	// Linear produces floating-point pixels, which would otherwise turn the
+	// composited image into a floating-point one as well.
+	if err := layer.Cast(format); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// tile repeats the given image across an area of the given width and height,
+// leaving the given gaps between each copy.
+func tile(layer *vips.ImageRef, gapX, gapY, width, height int) error {
+	if gapX > 0 || gapY > 0 {
+		// Padding with black makes the gaps transparent, as the alpha channel
+		// is padded with zeros as well.
+		err := layer.Embed(0, 0, layer.Width()+gapX, layer.Height()+gapY, vips.ExtendBlack)
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := layer.Embed(0, 0, width, height, vips.ExtendRepeat); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// position returns the coordinates of the top-left corner of an overlay of the
+// given size placed onto an image of the given size, offset from the edge
+// given by gravity.
+func position(gravity Gravity, offsetX, offsetY, width, height, overlayWidth, overlayHeight int) (x, y int) {
+	var (
+		centerX = int(math.Round(float64(width-overlayWidth) / 2))
+		centerY = int(math.Round(float64(height-overlayHeight) / 2))
+		right   = width - overlayWidth - offsetX
+		bottom  = height - overlayHeight - offsetY
+	)
+
+	switch gravity {
+	case GravityNorth:
+		return centerX + offsetX, offsetY
+	case GravityNorthEast:
+		return right, offsetY
+	case GravityEast:
+		return right, centerY + offsetY
+	case GravitySouthEast:
+		return right, bottom
+	case GravitySouth:
+		return centerX + offsetX, bottom
+	case GravitySouthWest:
+		return offsetX, bottom
+	case GravityWest:
+		return offsetX, centerY + offsetY
+	case GravityNorthWest:
+		return offsetX, offsetY
+	case GravityCenter:
+		fallthrough
+	default:
+		return centerX + offsetX, centerY + offsetY
+	}
+}
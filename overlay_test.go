@@ -0,0 +1,208 @@
+package imgdiet_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+func TestImage_Overlay(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    *imgdiet.OverlayOptions
+		nilLogo bool
+		err     error
+	}{
+		{
+			name: "default_options",
+			opts: nil,
+			err:  nil,
+		},
+		{
+			name: "scaled_and_faded",
+			opts: &imgdiet.OverlayOptions{
+				Gravity: imgdiet.GravityNorthWest,
+				X:       8,
+				Y:       8,
+				Scale:   0.5,
+				Opacity: 0.5,
+			},
+			err: nil,
+		},
+		{
+			name: "tiled",
+			opts: &imgdiet.OverlayOptions{
+				X:       10,
+				Y:       10,
+				Opacity: 0.3,
+				Tile:    true,
+			},
+			err: nil,
+		},
+		{
+			name: "invalid_opacity",
+			opts: &imgdiet.OverlayOptions{
+				Gravity: imgdiet.GravityCenter,
+			},
+			err: imgdiet.ErrInvalidOpacity,
+		},
+		{
+			name: "invalid_scale",
+			opts: &imgdiet.OverlayOptions{
+				Scale:   -1,
+				Opacity: 1,
+			},
+			err: imgdiet.ErrInvalidScale,
+		},
+		{
+			name:    "nil_overlay",
+			opts:    nil,
+			nilLogo: true,
+			err:     imgdiet.ErrNilImage,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			img := openTestImage(t, _TestDataPath+"/"+_TestValidImagePNG)
+
+			var logo *imgdiet.Image
+
+			if !tt.nilLogo {
+				logo = openTestImage(t, _TestDataPath+"/"+_TestTransparentImagePNG)
+			}
+
+			var (
+				width  = img.Width()
+				height = img.Height()
+			)
+
+			err := img.Overlay(logo, tt.opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if img.Width() != width || img.Height() != height {
+				t.Errorf("expected %dx%d image, got %dx%d", width, height, img.Width(), img.Height())
+			}
+
+			if _, err = img.Optimize(nil); err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestImage_Overlay_AnimatedOverlay(t *testing.T) {
+	t.Parallel()
+
+	var (
+		img  = openTestImage(t, _TestDataPath+"/"+_TestValidImagePNG)
+		logo = openTestImage(t, _TestDataPath+"/"+_TestValidImageGIF)
+	)
+
+	if err := img.Overlay(logo, nil); !errors.Is(err, imgdiet.ErrAnimatedImage) {
+		t.Fatalf("expected error %v, got %v", imgdiet.ErrAnimatedImage, err)
+	}
+}
+
+func TestImage_Overlay_AutoOrient(t *testing.T) {
+	t.Parallel()
+
+	img := openTestImage(t, _TestDataPath+"/"+_TestRotatedImageJPG)
+
+	square := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			square.SetNRGBA(x, y, color.NRGBA{B: 255, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, square); err != nil {
+		t.Fatalf("png.Encode() failed: %v", err)
+	}
+
+	logo, err := imgdiet.Open(&buf)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer logo.Close()
+
+	err = img.Overlay(logo, &imgdiet.OverlayOptions{
+		Gravity: imgdiet.GravityNorthWest,
+		Opacity: 1,
+	})
+	if err != nil {
+		t.Fatalf("Overlay() failed: %v", err)
+	}
+
+	// The image is stored sideways, 60x40, and displayed upright, 40x60.
+	if img.Width() != 40 || img.Height() != 60 {
+		t.Fatalf("expected 40x60 image, got %dx%d", img.Width(), img.Height())
+	}
+
+	data, err := img.Optimize(&imgdiet.Options{Format: imgdiet.ImageTypePNG})
+	if err != nil {
+		t.Fatalf("Optimize() failed: %v", err)
+	}
+
+	composited, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode() failed: %v", err)
+	}
+
+	// The displayed top-left corner is blue, and the top-right one, which is
+	// the stored top-left corner, keeps its red.
+	corners := []struct {
+		point image.Point
+		blue  bool
+	}{
+		{point: image.Point{X: 2, Y: 2}, blue: true},
+		{point: image.Point{X: 37, Y: 2}, blue: false},
+	}
+
+	for _, corner := range corners {
+		r, _, b, _ := composited.At(corner.point.X, corner.point.Y).RGBA()
+		if got := b>>8 > 128 && r>>8 < 128; got != corner.blue {
+			t.Errorf("pixel at %v = R %d B %d, want blue %t", corner.point, r>>8, b>>8, corner.blue)
+		}
+	}
+}
+
+// openTestImage opens the image at path, closing it when the test ends.
+func openTestImage(t *testing.T, path string) *imgdiet.Image {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	img, err := imgdiet.Open(file)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	t.Cleanup(img.Close)
+
+	return img
+}
@@ -0,0 +1,176 @@
+package imgdiet
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// List of presets registered by this package.
+const (
+	PresetDefault    string = "default"
+	PresetWeb        string = "web"
+	PresetLossless   string = "lossless"
+	PresetArchive    string = "archive"
+	PresetThumbnail  string = "thumbnail"
+	PresetAggressive string = "aggressive"
+	PresetPrivacy    string = "privacy"
+)
+
+const (
+	// ErrUnknownPreset is returned when no preset is registered under the
+	// given name.
+	ErrUnknownPreset xerrors.Error = "unknown preset"
+
+	// ErrInvalidPreset is returned when trying to register a preset without a
+	// name or without options.
+	ErrInvalidPreset xerrors.Error = "preset must have a name and options"
+)
+
+// presets holds every preset known to the package, keyed by name.
+var presets = &presetRegistry{ //nolint:gochecknoglobals // applications register their presets package-wide
+	options: map[string]*Options{
+		PresetDefault:    DefaultOptions(),
+		PresetWeb:        webOptions(),
+		PresetLossless:   losslessOptions(),
+		PresetArchive:    archiveOptions(),
+		PresetThumbnail:  thumbnailOptions(),
+		PresetAggressive: aggressiveOptions(),
+		PresetPrivacy:    privacyOptions(),
+	},
+}
+
+// presetRegistry is a concurrency-safe collection of named Options.
+type presetRegistry struct {
+	options map[string]*Options
+	mu      sync.RWMutex
+}
+
+// Preset returns a copy of the Options registered under the given name, so
+// callers are free to change individual fields without affecting the preset
+// itself.
+func Preset(name string) (*Options, error) {
+	presets.mu.RLock()
+	defer presets.mu.RUnlock()
+
+	opts, ok := presets.options[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPreset, name)
+	}
+
+	return opts.clone(), nil
+}
+
+// RegisterPreset registers the given Options under the given name, replacing
+// any preset previously registered with the same name, including the ones
+// provided by this package.
+func RegisterPreset(name string, opts *Options) error {
+	if name == "" || opts == nil {
+		return fmt.Errorf("%w", ErrInvalidPreset)
+	}
+
+	preset := opts.clone()
+
+	presets.mu.Lock()
+	defer presets.mu.Unlock()
+
+	presets.options[name] = preset
+
+	return nil
+}
+
+// Presets returns the names of all registered presets in alphabetical order.
+func Presets() []string {
+	presets.mu.RLock()
+	defer presets.mu.RUnlock()
+
+	names := make([]string, 0, len(presets.options))
+
+	for name := range presets.options {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// webOptions returns Options tuned for images served on the web, trading a
+// little more size than DefaultOptions for better visual quality and
+// progressive rendering. Colors are converted to sRGB, so images in wider
+// color spaces look the same in every browser.
+func webOptions() *Options {
+	opts := DefaultOptions()
+	opts.Quality = 75
+	opts.Interlaced = true
+	opts.ColorProfile = ColorProfileSRGB
+
+	return opts
+}
+
+// losslessOptions returns Options that avoid quality loss as much as each
+// format allows while still recompressing the image. Metadata, ICC profiles,
+// wide color gamuts, and 16-bit depth are kept untouched.
+func losslessOptions() *Options {
+	return &Options{
+		Quality:           100,
+		Compression:       9,
+		Effort:            7,
+		Bitdepth:          8,
+		OptimizeCoding:    true,
+		Lossless:          true,
+		PreserveWideGamut: true,
+		PreserveDepth:     true,
+		DropOpaqueAlpha:   true,
+		OptimizeScans:     true,
+	}
+}
+
+// archiveOptions returns Options meant for long-term storage, keeping
+// metadata, ICC profiles, wide color gamuts, and 16-bit depth, and using a high
+// quality setting.
+func archiveOptions() *Options {
+	return &Options{
+		Quality:           90,
+		Compression:       9,
+		Effort:            9,
+		Bitdepth:          8,
+		OptimizeCoding:    true,
+		PreserveWideGamut: true,
+		PreserveDepth:     true,
+		DropOpaqueAlpha:   true,
+		TrellisQuant:      true,
+		OptimizeScans:     true,
+	}
+}
+
+// thumbnailOptions returns Options meant for small previews, where visual
+// fidelity matters less than size.
+func thumbnailOptions() *Options {
+	opts := DefaultOptions()
+	opts.Quality = 50
+
+	return opts
+}
+
+// aggressiveOptions returns Options that favor the smallest possible output
+// at the expense of visible quality loss.
+func aggressiveOptions() *Options {
+	opts := DefaultOptions()
+	opts.Quality = 40
+	opts.Effort = 9
+	opts.Dither = 1
+
+	return opts
+}
+
+// privacyOptions returns DefaultOptions with a metadata policy that removes
+// location data and device identifiers instead of stripping every field.
+func privacyOptions() *Options {
+	opts := DefaultOptions()
+	opts.Metadata = PrivacyMetadataPolicy()
+
+	return opts
+}
@@ -0,0 +1,273 @@
+package imgdiet_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+func TestPreset(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		err  error
+	}{
+		{
+			name: "default",
+			give: imgdiet.PresetDefault,
+			err:  nil,
+		},
+		{
+			name: "web",
+			give: imgdiet.PresetWeb,
+			err:  nil,
+		},
+		{
+			name: "lossless",
+			give: imgdiet.PresetLossless,
+			err:  nil,
+		},
+		{
+			name: "archive",
+			give: imgdiet.PresetArchive,
+			err:  nil,
+		},
+		{
+			name: "thumbnail",
+			give: imgdiet.PresetThumbnail,
+			err:  nil,
+		},
+		{
+			name: "aggressive",
+			give: imgdiet.PresetAggressive,
+			err:  nil,
+		},
+		{
+			name: "privacy",
+			give: imgdiet.PresetPrivacy,
+			err:  nil,
+		},
+		{
+			name: "unknown",
+			give: "impossible-girl",
+			err:  imgdiet.ErrUnknownPreset,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imgdiet.Preset(tt.give)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err == nil && got == nil {
+				t.Fatal("expected non-nil options")
+			}
+		})
+	}
+}
+
+func TestPreset_ReturnsCopy(t *testing.T) {
+	t.Parallel()
+
+	first, err := imgdiet.Preset(imgdiet.PresetDefault)
+	if err != nil {
+		t.Fatalf("Preset() failed: %v", err)
+	}
+
+	first.Quality = 1
+
+	second, err := imgdiet.Preset(imgdiet.PresetDefault)
+	if err != nil {
+		t.Fatalf("Preset() failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(second, imgdiet.DefaultOptions()) {
+		t.Errorf("Preset() = %v, want %v", second, imgdiet.DefaultOptions())
+	}
+}
+
+func TestPreset_CopiesMetadataPolicy(t *testing.T) {
+	t.Parallel()
+
+	first, err := imgdiet.Preset(imgdiet.PresetPrivacy)
+	if err != nil {
+		t.Fatalf("Preset() failed: %v", err)
+	}
+
+	first.Metadata.Deny[0] = "impossible-girl"
+
+	second, err := imgdiet.Preset(imgdiet.PresetPrivacy)
+	if err != nil {
+		t.Fatalf("Preset() failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(second.Metadata, imgdiet.PrivacyMetadataPolicy()) {
+		t.Errorf("Preset().Metadata = %v, want %v", second.Metadata, imgdiet.PrivacyMetadataPolicy())
+	}
+}
+
+func TestRegisterPreset_CopiesOptions(t *testing.T) {
+	t.Parallel()
+
+	const name = "test-register-preset-copy"
+
+	newOptions := func() *imgdiet.Options {
+		return &imgdiet.Options{
+			Quality: 80,
+			Metadata: &imgdiet.MetadataPolicy{
+				Allow: []string{"exif-ifd0-Copyright"},
+				Deny:  []string{"exif-ifd3-*"},
+			},
+			Background: &imgdiet.Color{R: 255, G: 255, B: 255},
+		}
+	}
+
+	registered := newOptions()
+
+	if err := imgdiet.RegisterPreset(name, registered); err != nil {
+		t.Fatalf("RegisterPreset() failed: %v", err)
+	}
+
+	registered.Metadata.Allow[0] = "*"
+	registered.Metadata.Deny = nil
+	registered.Background.R = 0
+
+	returned, err := imgdiet.Preset(name)
+	if err != nil {
+		t.Fatalf("Preset() failed: %v", err)
+	}
+
+	returned.Metadata.Deny[0] = "*"
+	returned.Metadata.Allow = append(returned.Metadata.Allow, "xmp-data")
+	returned.Background.G = 0
+
+	got, err := imgdiet.Preset(name)
+	if err != nil {
+		t.Fatalf("Preset() failed: %v", err)
+	}
+
+	if want := newOptions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Preset() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPreset_Lossless(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		want bool
+	}{
+		{
+			name: "lossless",
+			give: imgdiet.PresetLossless,
+			want: true,
+		},
+		{
+			name: "archive",
+			give: imgdiet.PresetArchive,
+			want: false,
+		},
+		{
+			name: "web",
+			give: imgdiet.PresetWeb,
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imgdiet.Preset(tt.give)
+			if err != nil {
+				t.Fatalf("Preset() failed: %v", err)
+			}
+
+			if got.Lossless != tt.want {
+				t.Errorf("Preset().Lossless = %v, want %v", got.Lossless, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterPreset(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		preset  string
+		options *imgdiet.Options
+		err     error
+	}{
+		{
+			name:    "valid_preset",
+			preset:  "test-register-preset",
+			options: &imgdiet.Options{Quality: 42},
+			err:     nil,
+		},
+		{
+			name:    "empty_name",
+			preset:  "",
+			options: imgdiet.DefaultOptions(),
+			err:     imgdiet.ErrInvalidPreset,
+		},
+		{
+			name:    "nil_options",
+			preset:  "test-register-preset-nil",
+			options: nil,
+			err:     imgdiet.ErrInvalidPreset,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := imgdiet.RegisterPreset(tt.preset, tt.options)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			got, err := imgdiet.Preset(tt.preset)
+			if err != nil {
+				t.Fatalf("Preset() failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.options) {
+				t.Errorf("Preset() = %v, want %v", got, tt.options)
+			}
+
+			var found bool
+
+			for _, name := range imgdiet.Presets() {
+				if name == tt.preset {
+					found = true
+				}
+			}
+
+			if !found {
+				t.Errorf("Presets() does not contain %q", tt.preset)
+			}
+		})
+	}
+}
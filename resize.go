@@ -0,0 +1,228 @@
+package imgdiet
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Kernel defines the interpolation used to compute the pixels of a resized
+// image.
+type Kernel int
+
+// List of kernels supported by ResizeWithOptions.
+const (
+	// KernelDefault shrinks the image with a box filter before resizing it
+	// with Lanczos3, which is fast and sharp for photos. It is the only kernel
+	// allowing shrink-on-load.
+	KernelDefault Kernel = iota
+
+	// KernelNearest copies the nearest pixel, keeping hard edges intact for
+	// pixel art and icons.
+	KernelNearest
+
+	// KernelLinear interpolates between the two nearest pixels on each axis.
+	KernelLinear
+
+	// KernelCubic interpolates with a Catmull-Rom spline.
+	KernelCubic
+
+	// KernelMitchell interpolates with a Mitchell-Netravali filter, which
+	// rings less than Lanczos at the cost of some sharpness.
+	KernelMitchell
+
+	// KernelLanczos2 interpolates with a two-lobe Lanczos filter.
+	KernelLanczos2
+
+	// KernelLanczos3 interpolates with a three-lobe Lanczos filter.
+	KernelLanczos3
+)
+
+// vips returns the libvips kernel matching the kernel.
+func (k Kernel) vips() vips.Kernel {
+	switch k {
+	case KernelNearest:
+		return vips.KernelNearest
+	case KernelLinear:
+		return vips.KernelLinear
+	case KernelCubic:
+		return vips.KernelCubic
+	case KernelMitchell:
+		return vips.KernelMitchell
+	case KernelLanczos2:
+		return vips.KernelLanczos2
+	case KernelLanczos3, KernelDefault:
+		fallthrough
+	default:
+		return vips.KernelLanczos3
+	}
+}
+
+// targetSize returns the dimensions the image is resized to according to the
+// given ResizeOptions, multiplying them by the device pixel ratio, computing
+// the missing one from the aspect ratio of the image, and capping both at the
+// size allowed by MaxUpscale unless the image is a vector one.
+func (i *Image) targetSize(resize *ResizeOptions) (width, height int) {
+	var (
+		originalWidth  = float64(i.Width())
+		originalHeight = float64(i.Height())
+		upscale        = math.Max(1, resize.MaxUpscale)
+		maxWidth       = int(math.Round(originalWidth * upscale))
+		maxHeight      = int(math.Round(originalHeight * upscale))
+		dpr            = resize.dpr()
+	)
+
+	width = int(math.Round(float64(resize.Width) * dpr))
+	height = int(math.Round(float64(resize.Height) * dpr))
+
+	if width == 0 {
+		width = int(math.Round(float64(height) * originalWidth / originalHeight))
+	} else if height == 0 {
+		height = int(math.Round(float64(width) * originalHeight / originalWidth))
+	}
+
+	// Vector images are rasterized again at the target size, so they can be
+	// enlarged without losing quality.
+	if i.vector() {
+		return width, height
+	}
+
+	if width > maxWidth {
+		width = maxWidth
+	}
+
+	if height > maxHeight {
+		height = maxHeight
+	}
+
+	return width, height
+}
+
+// dpr returns the device pixel ratio requested by the ResizeOptions.
+func (o *ResizeOptions) dpr() float64 {
+	if o.DPR == 0 {
+		return 1
+	}
+
+	return o.DPR
+}
+
+// achievedDPR returns the device pixel ratio provided by an image of the given
+// dimensions, which is the lowest ratio between them and the requested ones.
+func (o *ResizeOptions) achievedDPR(width, height int) float64 {
+	dpr := o.dpr()
+
+	if o.Width > 0 {
+		dpr = math.Min(dpr, float64(width)/float64(o.Width))
+	}
+
+	if o.Height > 0 {
+		dpr = math.Min(dpr, float64(height)/float64(o.Height))
+	}
+
+	return dpr
+}
+
+// dprQuality returns the given quality divided by the square root of the
+// given device pixel ratio, and at least 1.
+func dprQuality(quality uint, dpr float64) uint {
+	lowered := uint(math.Round(float64(quality) / math.Sqrt(dpr)))
+	if lowered < 1 {
+		return 1
+	}
+
+	return lowered
+}
+
+// scale resizes the image to fill the given dimensions, cropping its center
+// if the aspect ratios differ, as configured by the given ResizeOptions.
+func (i *Image) scale(resize *ResizeOptions, width, height int) error {
+	if i.vector() {
+		return i.shrinkOnLoad(width, height)
+	}
+
+	// Thumbnailing and shrink-on-load only keep the first frame of animated
+	// images, so every frame is resampled instead. They also apply the EXIF
+	// orientation, which is only left on the image when the caller keeps the
+	// stored one.
+	if resize.Kernel != KernelDefault || i.Frames() > 1 || i.stored() {
+		return i.resample(width, height, resize.Kernel.vips())
+	}
+
+	if resize.ShrinkOnLoad && i.source != nil {
+		return i.shrinkOnLoad(width, height)
+	}
+
+	i.modified()
+
+	if err := i.reference.Thumbnail(width, height, vips.InterestingCentre); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// resample resizes the image with the given kernel to cover the given
+// dimensions, then crops its center to them. Each frame of animated images is
+// resized and cropped the same way.
+func (i *Image) resample(width, height int, kernel vips.Kernel) error {
+	i.modified()
+
+	var (
+		originalWidth  = float64(i.Width())
+		originalHeight = float64(i.Height())
+		cover          = math.Max(float64(width)/originalWidth, float64(height)/originalHeight)
+	)
+
+	// Scale each axis separately so rounding never leaves the image a pixel
+	// short of the requested dimensions.
+	var (
+		hscale = math.Max(float64(width), math.Round(originalWidth*cover)) / originalWidth
+		vscale = math.Max(float64(height), math.Round(originalHeight*cover)) / originalHeight
+	)
+
+	if err := i.reference.ResizeWithVScale(hscale, vscale, kernel); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	var (
+		resizedWidth  = i.Width()
+		resizedHeight = i.Height()
+	)
+
+	if resizedWidth < width {
+		width = resizedWidth
+	}
+
+	if resizedHeight < height {
+		height = resizedHeight
+	}
+
+	if width == resizedWidth && height == resizedHeight {
+		return nil
+	}
+
+	err := i.reference.ExtractArea((resizedWidth-width)/2, (resizedHeight-height)/2, width, height)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// shrinkOnLoad decodes the image again from its source at the given
+// dimensions, letting decoders such as the JPEG one skip most of the pixels
+// of large images, and vector images be rasterized at the right size.
+func (i *Image) shrinkOnLoad(width, height int) error {
+	reference, err := vips.LoadThumbnailFromBuffer(i.source, width, height, vips.InterestingCentre, vips.SizeBoth, nil)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	i.reference.Close()
+	i.reference = reference
+	i.modified()
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package imgdiet
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+// ErrExternalReference is returned when opening an SVG image referencing files
+// or URLs outside of itself without OpenOptions.AllowExternalReferences.
+const ErrExternalReference xerrors.Error = "SVG image references external resources"
+
+// cssReference matches the URLs referenced by CSS, either with url() or with
+// an @import rule.
+var cssReference = regexp.MustCompile(`(?i)url\(\s*['"]?([^'")\s]*)|@import\s+['"]([^'"]*)`) //nolint:gochecknoglobals // compiled once
+
+// stylesheetReference matches the URL referenced by the href pseudo-attribute
+// of an xml-stylesheet processing instruction.
+var stylesheetReference = regexp.MustCompile(`href\s*=\s*['"]([^'"]*)`) //nolint:gochecknoglobals // compiled once
+
+// isSVG reports whether the given image is an SVG image, which is an XML
+// document whose root element is svg.
+func isSVG(image []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(image))
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+
+		if element, ok := token.(xml.StartElement); ok {
+			return element.Name.Local == "svg"
+		}
+	}
+}
+
+// checkSVGReferences returns an error if the given SVG image references
+// resources outside of itself, either with links such as <image href="...">,
+// with CSS, including url() in presentation attributes such as fill, with
+// xml-stylesheet processing instructions, or with external XML entities.
+// Fragments such as #gradient and data: URLs are allowed.
+func checkSVGReferences(image []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(image))
+	decoder.Strict = false
+
+	var inStyle bool
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		switch token := token.(type) {
+		case xml.ProcInst:
+			if token.Target != "xml-stylesheet" {
+				continue
+			}
+
+			if match := stylesheetReference.FindSubmatch(token.Inst); match != nil && external(string(match[1])) {
+				return fmt.Errorf("%w: %s", ErrExternalReference, match[1])
+			}
+		case xml.Directive:
+			if externalEntity(string(token)) {
+				return fmt.Errorf("%w: external entity", ErrExternalReference)
+			}
+		case xml.StartElement:
+			inStyle = token.Name.Local == "style"
+
+			for _, attr := range token.Attr {
+				var reference string
+
+				// Besides links, the style attribute and presentation
+				// attributes such as fill, filter, or mask may reference
+				// resources with url().
+				switch attr.Name.Local {
+				case "href", "src":
+					reference = attr.Value
+				default:
+					reference = cssExternalReference(attr.Value)
+				}
+
+				if external(reference) {
+					return fmt.Errorf("%w: %s", ErrExternalReference, reference)
+				}
+			}
+		case xml.EndElement:
+			inStyle = false
+		case xml.CharData:
+			if !inStyle {
+				continue
+			}
+
+			if reference := cssExternalReference(string(token)); reference != "" {
+				return fmt.Errorf("%w: %s", ErrExternalReference, reference)
+			}
+		}
+	}
+}
+
+// cssExternalReference returns the first external URL referenced by the given
+// CSS, or an empty string if there is none.
+func cssExternalReference(css string) string {
+	for _, match := range cssReference.FindAllStringSubmatch(css, -1) {
+		reference := match[1] + match[2]
+
+		if external(reference) {
+			return reference
+		}
+	}
+
+	return ""
+}
+
+// external reports whether the given reference points outside of the
+// document, which is anything but a fragment or a data: URL.
+func external(reference string) bool {
+	reference = strings.TrimSpace(reference)
+
+	if reference == "" || strings.HasPrefix(reference, "#") {
+		return false
+	}
+
+	return !strings.HasPrefix(strings.ToLower(reference), "data:")
+}
+
+// externalEntity reports whether the given document type declaration declares
+// an entity loaded from outside of the document.
+func externalEntity(directive string) bool {
+	upper := strings.ToUpper(directive)
+
+	return strings.Contains(upper, "<!ENTITY") && (strings.Contains(upper, "SYSTEM") || strings.Contains(upper, "PUBLIC"))
+}
@@ -0,0 +1,249 @@
+package imgdiet_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+func TestOpenWithOptions_SVG(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		give       string
+		opts       *imgdiet.OpenOptions
+		wantWidth  int
+		wantHeight int
+		err        error
+	}{
+		{
+			name:       "default_density",
+			give:       `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24"><rect width="24" height="24"/></svg>`,
+			opts:       nil,
+			wantWidth:  24,
+			wantHeight: 24,
+			err:        nil,
+		},
+		{
+			name: "double_density",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24"><rect width="24" height="24"/></svg>`,
+			opts: &imgdiet.OpenOptions{
+				DPI: 144,
+			},
+			wantWidth:  48,
+			wantHeight: 48,
+			err:        nil,
+		},
+		{
+			name: "fragment_and_data_references",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="24" height="24">
+				<defs><rect id="square" width="24" height="24"/></defs>
+				<use xlink:href="#square"/>
+				<image href="data:image/gif;base64,R0lGODlhAQABAAAAACw=" width="1" height="1"/>
+			</svg>`,
+			opts:       nil,
+			wantWidth:  24,
+			wantHeight: 24,
+			err:        nil,
+		},
+		{
+			name: "allowed_external_image",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24">
+				<image href="https://example.com/image.png" width="24" height="24"/>
+			</svg>`,
+			opts: &imgdiet.OpenOptions{
+				AllowExternalReferences: true,
+			},
+			wantWidth:  24,
+			wantHeight: 24,
+			err:        nil,
+		},
+		{
+			name: "external_image",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24">
+				<image href="https://example.com/image.png" width="24" height="24"/>
+			</svg>`,
+			opts: nil,
+			err:  imgdiet.ErrExternalReference,
+		},
+		{
+			name: "external_entity",
+			give: `<?xml version="1.0"?>
+			<!DOCTYPE svg [<!ENTITY secret SYSTEM "file:///etc/passwd">]>
+			<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24"><text>&secret;</text></svg>`,
+			opts: nil,
+			err:  imgdiet.ErrExternalReference,
+		},
+		{
+			name: "external_stylesheet",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24">
+				<style>@import "https://example.com/style.css";</style>
+			</svg>`,
+			opts: nil,
+			err:  imgdiet.ErrExternalReference,
+		},
+		{
+			name: "fragment_paint",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24">
+				<defs><linearGradient id="gradient"><stop offset="0" stop-color="red"/></linearGradient></defs>
+				<rect width="24" height="24" fill="url(#gradient)" stroke="url( '#gradient' )"/>
+			</svg>`,
+			opts:       nil,
+			wantWidth:  24,
+			wantHeight: 24,
+			err:        nil,
+		},
+		{
+			name: "external_xml_stylesheet",
+			give: `<?xml version="1.0"?>
+			<?xml-stylesheet type="text/css" href="https://example.com/style.css"?>
+			<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24"><rect width="24" height="24"/></svg>`,
+			opts: nil,
+			err:  imgdiet.ErrExternalReference,
+		},
+		{
+			name: "external_fill",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24">
+				<rect width="24" height="24" fill="url(https://example.com/paint.svg#fill)"/>
+			</svg>`,
+			opts: nil,
+			err:  imgdiet.ErrExternalReference,
+		},
+		{
+			name: "external_filter",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24">
+				<rect width="24" height="24" filter="url('filters.svg#blur')"/>
+			</svg>`,
+			opts: nil,
+			err:  imgdiet.ErrExternalReference,
+		},
+		{
+			name: "external_mask",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24">
+				<rect width="24" height="24" mask="url(masks.svg#mask)"/>
+			</svg>`,
+			opts: nil,
+			err:  imgdiet.ErrExternalReference,
+		},
+		{
+			name: "external_clip_path",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24">
+				<rect width="24" height="24" clip-path="url(clip.svg#circle)"/>
+			</svg>`,
+			opts: nil,
+			err:  imgdiet.ErrExternalReference,
+		},
+		{
+			name: "external_marker",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24">
+				<path d="M0 0L24 24" stroke="black" marker-end="url(markers.svg#arrow)"/>
+			</svg>`,
+			opts: nil,
+			err:  imgdiet.ErrExternalReference,
+		},
+		{
+			name: "external_style_attribute",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24">
+				<rect width="24" height="24" style="fill: url('https://example.com/paint.svg#fill')"/>
+			</svg>`,
+			opts: nil,
+			err:  imgdiet.ErrExternalReference,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image, err := imgdiet.OpenWithOptions(strings.NewReader(tt.give), tt.opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+			defer image.Close()
+
+			if image.Format() != imgdiet.ImageTypeSVG {
+				t.Errorf("expected %s image, got %s", imgdiet.ImageTypeSVG, image.Format())
+			}
+
+			if image.Width() != tt.wantWidth || image.Height() != tt.wantHeight {
+				t.Errorf("expected %dx%d image, got %dx%d", tt.wantWidth, tt.wantHeight, image.Width(), image.Height())
+			}
+		})
+	}
+}
+
+func TestOpenWithOptions_SVG_Malformed(t *testing.T) {
+	t.Parallel()
+
+	give := `<svg xmlns="http://www.w3.org/2000/svg" width="24" height="24"><rect width="24></svg>`
+
+	_, err := imgdiet.OpenWithOptions(strings.NewReader(give), nil)
+	if !errors.Is(err, imgdiet.ErrOpenImage) {
+		t.Fatalf("expected error %v, got %v", imgdiet.ErrOpenImage, err)
+	}
+
+	if count := strings.Count(err.Error(), imgdiet.ErrOpenImage.Error()); count != 1 {
+		t.Errorf("expected %v once in the error, got %d times: %v", imgdiet.ErrOpenImage, count, err)
+	}
+}
+
+func TestImage_Resize_SVG(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		size uint
+	}{
+		{
+			name: "smaller_than_source",
+			size: 16,
+		},
+		{
+			name: "larger_than_source",
+			size: 512,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Open(_TestDataPath + "/" + _TestVectorImageSVG)
+			if err != nil {
+				t.Fatalf("unable to open file: %v", err)
+			}
+			defer file.Close()
+
+			image, err := imgdiet.Open(file)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer image.Close()
+
+			optimized, err := image.Resize(tt.size, tt.size, imgdiet.DefaultOptions())
+			if err != nil {
+				t.Fatalf("Resize() failed: %v", err)
+			}
+
+			if image.Width() != int(tt.size) || image.Height() != int(tt.size) {
+				t.Errorf("expected %dx%d image, got %dx%d", tt.size, tt.size, image.Width(), image.Height())
+			}
+
+			if got, _ := imgdiet.DetectImageType(optimized); got != imgdiet.ImageTypePNG {
+				t.Errorf("expected %s image, got %s", imgdiet.ImageTypePNG, got)
+			}
+		})
+	}
+}
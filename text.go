@@ -0,0 +1,371 @@
+package imgdiet
+
+import (
+	"fmt"
+	"strconv"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+const (
+	// ErrEmptyText is returned when trying to draw an empty text.
+	ErrEmptyText xerrors.Error = "text must not be empty"
+
+	// ErrInvalidFontSize is returned when the font size of a text is not
+	// positive.
+	ErrInvalidFontSize xerrors.Error = "font size must be greater than 0"
+)
+
+// TextAlign defines how the lines of a wrapped text are aligned to each other.
+type TextAlign int
+
+// List of text alignments supported by DrawText.
+const (
+	TextAlignLeft TextAlign = iota
+	TextAlignCenter
+	TextAlignRight
+)
+
+// TextShadow represents a drop shadow drawn behind a text.
+type TextShadow struct {
+	// Color is the color of the shadow.
+	Color Color
+
+	// X is the horizontal offset in pixels of the shadow from the text.
+	X int
+
+	// Y is the vertical offset in pixels of the shadow from the text.
+	Y int
+
+	// Opacity defines the opacity of the shadow. It is a number greater than
+	// 0 and at most 1, where 1 is fully opaque.
+	Opacity float64
+}
+
+// TextBox represents a solid box drawn behind a text to keep it readable on
+// busy backgrounds.
+type TextBox struct {
+	// Color is the color of the box.
+	Color Color
+
+	// Padding is the space in pixels between the text and the edges of the
+	// box.
+	Padding int
+
+	// Opacity defines the opacity of the box. It is a number greater than 0
+	// and at most 1, where 1 is fully opaque.
+	Opacity float64
+}
+
+// TextOptions represents the parameters used to draw a text onto an image.
+type TextOptions struct {
+	// Shadow is the drop shadow drawn behind the text. If nil, no shadow is
+	// drawn.
+	Shadow *TextShadow
+
+	// Box is the box drawn behind the text. If nil, no box is drawn.
+	Box *TextBox
+
+	// Font is the Pango description of a font installed on the system, such
+	// as "Noto Sans" or "DejaVu Serif Bold", without a size. Fonts are looked
+	// up using fontconfig, falling back to a similar font if not found.
+	Font string
+
+	// Size is the font size in pixels.
+	Size int
+
+	// Width is the width in pixels at which the text is wrapped into multiple
+	// lines. If zero, the text is only broken on newlines.
+	Width int
+
+	// X is the horizontal distance in pixels between the text and the edge
+	// given by Gravity.
+	X int
+
+	// Y is the vertical distance in pixels between the text and the edge given
+	// by Gravity.
+	Y int
+
+	// Opacity defines the opacity of the text. It is a number greater than 0
+	// and at most 1, where 1 is fully opaque.
+	Opacity float64
+
+	// Gravity defines the edge or corner of the image the text is placed
+	// against.
+	Gravity Gravity
+
+	// Align defines how the lines of the text are aligned to each other.
+	Align TextAlign
+
+	// Color is the color of the text.
+	Color Color
+}
+
+// DefaultTextOptions returns a set of defaults for drawing a white title in
+// the center of an image.
+func DefaultTextOptions() *TextOptions {
+	return &TextOptions{
+		Font:    "sans",
+		Size:    48,
+		Opacity: 1,
+		Gravity: GravityCenter,
+		Align:   TextAlignCenter,
+		Color:   Color{R: 255, G: 255, B: 255},
+	}
+}
+
+// DrawText draws the given text onto the image according to the given
+// TextOptions, or DefaultTextOptions if nil. The text may contain Pango markup,
+// such as <b>bold</b>, and newlines. It is placed relative to the image as it
+// is displayed, applying its EXIF orientation first.
+//
+// The text is rendered at most as large as the image, and any part of it
+// falling outside of the image is cut off. Animated images are not supported.
+func (i *Image) DrawText(text string, opts *TextOptions) error {
+	if opts == nil {
+		opts = DefaultTextOptions()
+	}
+
+	if err := validateText(text, opts); err != nil {
+		return err
+	}
+
+	if err := i.still(); err != nil {
+		return err
+	}
+
+	if err := i.upright(); err != nil {
+		return err
+	}
+
+	mask, err := textMask(text, opts, i.reference.Width(), i.reference.Height())
+	if err != nil {
+		return err
+	}
+
+	// Text made of whitespace only has nothing to draw.
+	if mask == nil {
+		return nil
+	}
+	defer mask.Close()
+
+	var (
+		width   = mask.Width()
+		height  = mask.Height()
+		padding int
+		shiftX  int
+		shiftY  int
+	)
+
+	if opts.Box != nil {
+		padding = opts.Box.Padding
+	}
+
+	// Make room for the shadow so it is part of the block positioned by
+	// Gravity, and covered by the box.
+	if opts.Shadow != nil {
+		if opts.Shadow.X < 0 {
+			shiftX = -opts.Shadow.X
+		}
+
+		if opts.Shadow.Y < 0 {
+			shiftY = -opts.Shadow.Y
+		}
+
+		width += abs(opts.Shadow.X)
+		height += abs(opts.Shadow.Y)
+	}
+
+	width += 2 * padding
+	height += 2 * padding
+
+	x, y := position(opts.Gravity, opts.X, opts.Y, i.reference.Width(), i.reference.Height(), width, height)
+
+	if opts.Box != nil {
+		if err = i.drawLayer(opts.Box.Color, nil, width, height, opts.Box.Opacity, x, y); err != nil {
+			return err
+		}
+	}
+
+	var (
+		textX = x + padding + shiftX
+		textY = y + padding + shiftY
+	)
+
+	if opts.Shadow != nil {
+		err = i.drawLayer(
+			opts.Shadow.Color,
+			mask,
+			mask.Width(),
+			mask.Height(),
+			opts.Shadow.Opacity,
+			textX+opts.Shadow.X,
+			textY+opts.Shadow.Y,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return i.drawLayer(opts.Color, mask, mask.Width(), mask.Height(), opts.Opacity, textX, textY)
+}
+
+// validateText returns an error if the given text or TextOptions cannot be
+// drawn.
+func validateText(text string, opts *TextOptions) error {
+	if text == "" {
+		return fmt.Errorf("%w", ErrEmptyText)
+	}
+
+	if opts.Size <= 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidFontSize, opts.Size)
+	}
+
+	opacities := []float64{opts.Opacity}
+
+	if opts.Shadow != nil {
+		opacities = append(opacities, opts.Shadow.Opacity)
+	}
+
+	if opts.Box != nil {
+		opacities = append(opacities, opts.Box.Opacity)
+	}
+
+	for _, opacity := range opacities {
+		if opacity <= 0 || opacity > 1 {
+			return fmt.Errorf("%w: %v", ErrInvalidOpacity, opacity)
+		}
+	}
+
+	return nil
+}
+
+// textMask renders the given text into a single-band image cropped to the
+// text, where each pixel is the coverage of the text, or returns nil if the
+// text has nothing to draw. The text is rendered onto a canvas of the given
+// size.
+func textMask(text string, opts *TextOptions, width, height int) (*vips.ImageRef, error) {
+	mask, err := vips.Black(width, height)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	// Drawing the text in white onto a black canvas leaves the coverage of
+	// each pixel as its value.
+	err = mask.Label(&vips.LabelParams{
+		Text:      text,
+		Font:      opts.Font + " " + strconv.Itoa(opts.Size),
+		Width:     vips.Scalar{Value: float64(opts.Width)},
+		Opacity:   1,
+		Color:     vips.Color{R: 255, G: 255, B: 255},
+		Alignment: opts.Align.vips(),
+	})
+	if err != nil {
+		mask.Close()
+
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	left, top, trimmedWidth, trimmedHeight, err := mask.FindTrim(0, &vips.Color{})
+	if err != nil {
+		mask.Close()
+
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if trimmedWidth == 0 || trimmedHeight == 0 {
+		mask.Close()
+
+		return nil, nil //nolint:nilnil // A nil mask means there is nothing to draw.
+	}
+
+	if err = mask.ExtractArea(left, top, trimmedWidth, trimmedHeight); err != nil {
+		mask.Close()
+
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if err = mask.ExtractBand(0, 1); err != nil {
+		mask.Close()
+
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return mask, nil
+}
+
+// drawLayer composites a layer of the given color and size onto the image at
+// the given coordinates. The alpha channel of the layer is the given mask, or
+// fully opaque if nil, multiplied by opacity.
+func (i *Image) drawLayer(color Color, mask *vips.ImageRef, width, height int, opacity float64, x, y int) error {
+	layer, err := vips.Black(width, height)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer layer.Close()
+
+	err = layer.Linear([]float64{1, 1, 1}, []float64{float64(color.R), float64(color.G), float64(color.B)})
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = layer.Cast(vips.BandFormatUchar); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if mask == nil {
+		err = layer.BandJoinConst([]float64{255})
+	} else {
+		err = layer.BandJoin(mask)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if opacity < 1 {
+		if err = fade(layer, opacity); err != nil {
+			return err
+		}
+	}
+
+	// The layer is built from a single-band black image, so it has to be
+	// tagged as sRGB for its bands to be composited as colors.
+	colored, err := layer.CopyChangingInterpretation(vips.InterpretationSRGB)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer colored.Close()
+
+	i.modified()
+
+	if err = i.reference.Composite(colored, vips.BlendModeOver, x, y); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// vips returns the libvips alignment matching the text alignment.
+func (a TextAlign) vips() vips.Align {
+	switch a {
+	case TextAlignCenter:
+		return vips.AlignCenter
+	case TextAlignRight:
+		return vips.AlignHigh
+	case TextAlignLeft:
+		fallthrough
+	default:
+		return vips.AlignLow
+	}
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
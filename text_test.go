@@ -0,0 +1,163 @@
+package imgdiet_test
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+func TestImage_DrawText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		text string
+		opts *imgdiet.TextOptions
+		err  error
+	}{
+		{
+			name: "default_options",
+			text: "Impossible Girl",
+			opts: nil,
+			err:  nil,
+		},
+		{
+			name: "wrapped_text_with_shadow_and_box",
+			text: "The <b>Impossible Girl</b> and the Doctor",
+			opts: &imgdiet.TextOptions{
+				Shadow: &imgdiet.TextShadow{
+					X:       2,
+					Y:       2,
+					Opacity: 0.6,
+				},
+				Box: &imgdiet.TextBox{
+					Color:   imgdiet.Color{R: 32, G: 32, B: 32},
+					Padding: 12,
+					Opacity: 0.5,
+				},
+				Font:    "sans bold",
+				Size:    24,
+				Width:   200,
+				X:       16,
+				Y:       16,
+				Opacity: 1,
+				Gravity: imgdiet.GravitySouthWest,
+				Align:   imgdiet.TextAlignLeft,
+				Color:   imgdiet.Color{R: 255, G: 200, B: 0},
+			},
+			err: nil,
+		},
+		{
+			name: "whitespace_text",
+			text: "   ",
+			opts: nil,
+			err:  nil,
+		},
+		{
+			name: "empty_text",
+			text: "",
+			opts: nil,
+			err:  imgdiet.ErrEmptyText,
+		},
+		{
+			name: "invalid_font_size",
+			text: "Impossible Girl",
+			opts: &imgdiet.TextOptions{
+				Opacity: 1,
+			},
+			err: imgdiet.ErrInvalidFontSize,
+		},
+		{
+			name: "invalid_box_opacity",
+			text: "Impossible Girl",
+			opts: &imgdiet.TextOptions{
+				Box:     &imgdiet.TextBox{},
+				Size:    24,
+				Opacity: 1,
+			},
+			err: imgdiet.ErrInvalidOpacity,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, _TestDataPath+"/"+_TestValidImagePNG)
+
+			var (
+				width  = image.Width()
+				height = image.Height()
+			)
+
+			err := image.DrawText(tt.text, tt.opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if image.Width() != width || image.Height() != height {
+				t.Errorf("expected %dx%d image, got %dx%d", width, height, image.Width(), image.Height())
+			}
+
+			if _, err = image.Optimize(nil); err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestImage_DrawText_AutoOrient(t *testing.T) {
+	t.Parallel()
+
+	img := openTestImage(t, _TestDataPath+"/"+_TestRotatedImageJPG)
+
+	err := img.DrawText(".", &imgdiet.TextOptions{
+		Box: &imgdiet.TextBox{
+			Color:   imgdiet.Color{B: 255},
+			Padding: 8,
+			Opacity: 1,
+		},
+		Font:    "sans",
+		Size:    8,
+		Opacity: 1,
+		Gravity: imgdiet.GravityNorthWest,
+		Color:   imgdiet.Color{B: 255},
+	})
+	if err != nil {
+		t.Fatalf("DrawText() failed: %v", err)
+	}
+
+	// The image is stored sideways, 60x40, and displayed upright, 40x60.
+	if img.Width() != 40 || img.Height() != 60 {
+		t.Fatalf("expected 40x60 image, got %dx%d", img.Width(), img.Height())
+	}
+
+	data, err := img.Optimize(&imgdiet.Options{Format: imgdiet.ImageTypePNG})
+	if err != nil {
+		t.Fatalf("Optimize() failed: %v", err)
+	}
+
+	drawn, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode() failed: %v", err)
+	}
+
+	// The box covers the displayed top-left corner, while the top-right one,
+	// which is the stored top-left corner, keeps its red.
+	if r, _, b, _ := drawn.At(2, 2).RGBA(); b>>8 < 128 || r>>8 > 128 {
+		t.Errorf("expected a blue top-left corner, got R %d B %d", r>>8, b>>8)
+	}
+
+	if r, _, b, _ := drawn.At(37, 2).RGBA(); b>>8 > 128 || r>>8 < 128 {
+		t.Errorf("expected a red top-right corner, got R %d B %d", r>>8, b>>8)
+	}
+}
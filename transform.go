@@ -0,0 +1,279 @@
+package imgdiet
+
+import (
+	"fmt"
+	"math"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// ErrInvalidCropArea is returned when the area to crop an image to is empty or
+// not entirely within the image.
+const ErrInvalidCropArea xerrors.Error = "crop area must be within the image and not empty"
+
+// DefaultTrimThreshold is the default maximum difference between a pixel and
+// the background color for the pixel to be trimmed by Trim.
+const DefaultTrimThreshold float64 = 10
+
+// FlipDirection defines the axis an image is mirrored along.
+type FlipDirection int
+
+// List of directions supported by Flip.
+const (
+	// FlipHorizontal mirrors the image left to right.
+	FlipHorizontal FlipDirection = iota
+
+	// FlipVertical mirrors the image top to bottom.
+	FlipVertical
+)
+
+// Rotate rotates the image clockwise by the given angle in degrees.
+//
+// Multiples of 90 degrees are lossless. Other angles enlarge the image to fit
+// the rotated one, filling the corners with the given background color, or
+// leaving them transparent if nil. Transparent corners are blended onto
+// Options.Background when the image is optimized to a format without
+// transparency. Animated images cannot be rotated.
+func (i *Image) Rotate(angle float64, background *Color) error {
+	if err := i.upright(); err != nil {
+		return err
+	}
+
+	angle = math.Mod(angle, 360)
+	if angle < 0 {
+		angle += 360
+	}
+
+	if angle == 0 {
+		return nil
+	}
+
+	if err := i.still(); err != nil {
+		return err
+	}
+
+	i.modified()
+
+	if math.Mod(angle, 90) == 0 {
+		if err := i.reference.Rotate(rightAngle(angle)); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return nil
+	}
+
+	// The background is given as an sRGB color, so grayscale images have to
+	// be converted for it to apply.
+	if i.reference.Bands() < 3 {
+		if err := i.reference.ToColorSpace(vips.InterpretationSRGB); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	fill := &vips.ColorRGBA{}
+
+	if background == nil {
+		if !i.reference.HasAlpha() {
+			if err := i.reference.AddAlpha(); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		}
+	} else {
+		fill = &vips.ColorRGBA{R: background.R, G: background.G, B: background.B, A: 255}
+	}
+
+	if err := i.reference.Similarity(1, angle, fill, 0, 0, 0, 0); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Flip mirrors the image in the given direction. Animated images can only be
+// flipped horizontally.
+func (i *Image) Flip(direction FlipDirection) error {
+	if err := i.upright(); err != nil {
+		return err
+	}
+
+	axis := vips.DirectionHorizontal
+
+	if direction == FlipVertical {
+		// The frames of animated images are stacked vertically, so flipping
+		// them all at once would reverse their order.
+		if err := i.still(); err != nil {
+			return err
+		}
+
+		axis = vips.DirectionVertical
+	}
+
+	i.modified()
+
+	if err := i.reference.Flip(axis); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// Crop crops the image to the rectangle of the given size whose top-left
+// corner is at the given coordinates, all in pixels. Every frame of animated
+// images is cropped to the same rectangle.
+func (i *Image) Crop(left, top, width, height int) error {
+	if err := i.upright(); err != nil {
+		return err
+	}
+
+	if left < 0 || top < 0 || width <= 0 || height <= 0 ||
+		left+width > i.Width() || top+height > i.Height() {
+		return fmt.Errorf("%w: %dx%d at %d,%d", ErrInvalidCropArea, width, height, left, top)
+	}
+
+	i.modified()
+
+	if err := i.reference.ExtractArea(left, top, width, height); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// CropPercent crops the image like Crop, with the coordinates and size given
+// as percentages of the width and height of the image, such as 25 for a
+// quarter of it. They are rounded to the nearest pixel.
+func (i *Image) CropPercent(left, top, width, height float64) error {
+	if err := i.upright(); err != nil {
+		return err
+	}
+
+	if left < 0 || top < 0 || width <= 0 || height <= 0 || left+width > 100 || top+height > 100 {
+		return fmt.Errorf("%w: %v%%x%v%% at %v%%,%v%%", ErrInvalidCropArea, width, height, left, top)
+	}
+
+	var (
+		imageWidth  = float64(i.Width())
+		imageHeight = float64(i.Height())
+		x           = int(math.Round(left * imageWidth / 100))
+		y           = int(math.Round(top * imageHeight / 100))
+		right       = int(math.Round((left + width) * imageWidth / 100))
+		bottom      = int(math.Round((top + height) * imageHeight / 100))
+	)
+
+	// Keep at least one pixel when the area is too small to be rounded to
+	// one.
+	if right == x {
+		right++
+	}
+
+	if bottom == y {
+		bottom++
+	}
+
+	return i.Crop(x, y, right-x, bottom-y)
+}
+
+// Trim crops away the uniform borders of the image, such as the white margins
+// of a scanned document or of a product shot. Pixels differing from the given
+// background color by at most threshold are part of the borders. If background
+// is nil, the color of the top-left pixel is used.
+//
+// It reports whether the image was cropped. Images made only of background are
+// left untouched. The borders of animated images are found in their first
+// frame.
+func (i *Image) Trim(threshold float64, background *Color) (bool, error) {
+	if err := i.upright(); err != nil {
+		return false, err
+	}
+
+	// Search a copy converted to 8-bit sRGB, which is what the background
+	// color and threshold refer to.
+	search, err := i.reference.Copy()
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+	defer search.Close()
+
+	// The borders of animated images are searched in their first frame, and
+	// trimmed from every frame.
+	if i.Frames() > 1 {
+		if err = search.SetPageHeight(search.Height()); err != nil {
+			return false, fmt.Errorf("%w", err)
+		}
+
+		if err = search.ExtractArea(0, 0, i.Width(), i.Height()); err != nil {
+			return false, fmt.Errorf("%w", err)
+		}
+	}
+
+	if err = search.ToColorSpace(vips.InterpretationSRGB); err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+
+	if background == nil {
+		background, err = cornerColor(search)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	left, top, width, height, err := search.FindTrim(threshold, &vips.Color{
+		R: background.R,
+		G: background.G,
+		B: background.B,
+	})
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+
+	if width == 0 || height == 0 || (width == i.Width() && height == i.Height()) {
+		return false, nil
+	}
+
+	i.modified()
+
+	if err = i.reference.ExtractArea(left, top, width, height); err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+
+	return true, nil
+}
+
+// upright applies the EXIF orientation of the image to its pixels, so
+// geometric transforms apply to the image as it is displayed.
+func (i *Image) upright() error {
+	if _, err := i.AutoOrient(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// cornerColor returns the color of the top-left pixel of the given sRGB image.
+func cornerColor(image *vips.ImageRef) (*Color, error) {
+	pixel, err := image.GetPoint(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return &Color{
+		R: uint8(math.Round(pixel[0])),
+		G: uint8(math.Round(pixel[1])),
+		B: uint8(math.Round(pixel[2])),
+	}, nil
+}
+
+// rightAngle returns the libvips angle matching the given multiple of 90
+// degrees, between 90 and 270.
+func rightAngle(angle float64) vips.Angle {
+	if angle == 90 {
+		return vips.Angle90
+	}
+
+	if angle == 180 {
+		return vips.Angle180
+	}
+
+	return vips.Angle270
+}
@@ -0,0 +1,322 @@
+package imgdiet_test
+
+import (
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/imgdiet-go"
+)
+
+func TestImage_Rotate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		angle      float64
+		background *imgdiet.Color
+		wantSwap   bool
+		wantLarger bool
+	}{
+		{
+			name:     "right_angle",
+			angle:    90,
+			wantSwap: true,
+		},
+		{
+			name:     "negative_right_angle",
+			angle:    -90,
+			wantSwap: true,
+		},
+		{
+			name:  "upside_down",
+			angle: 180,
+		},
+		{
+			name:  "full_turn",
+			angle: 360,
+		},
+		{
+			name:       "arbitrary_angle_with_transparent_corners",
+			angle:      30,
+			wantLarger: true,
+		},
+		{
+			name:       "arbitrary_angle_with_background",
+			angle:      -12.5,
+			background: &imgdiet.Color{R: 255, G: 0, B: 255},
+			wantLarger: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, _TestDataPath+"/"+_TestBorderedImagePNG)
+
+			var (
+				width  = image.Width()
+				height = image.Height()
+			)
+
+			if err := image.Rotate(tt.angle, tt.background); err != nil {
+				t.Fatalf("Rotate() failed: %v", err)
+			}
+
+			switch {
+			case tt.wantSwap:
+				if image.Width() != height || image.Height() != width {
+					t.Errorf("expected %dx%d image, got %dx%d", height, width, image.Width(), image.Height())
+				}
+			case tt.wantLarger:
+				if image.Width() <= width || image.Height() <= height {
+					t.Errorf("expected image larger than %dx%d, got %dx%d", width, height, image.Width(), image.Height())
+				}
+
+				if tt.background == nil && !image.HasAlpha() {
+					t.Error("expected transparent corners")
+				}
+			default:
+				if image.Width() != width || image.Height() != height {
+					t.Errorf("expected %dx%d image, got %dx%d", width, height, image.Width(), image.Height())
+				}
+			}
+
+			if _, err := image.Optimize(nil); err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestImage_Flip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		direction imgdiet.FlipDirection
+	}{
+		{
+			name:      "horizontal",
+			direction: imgdiet.FlipHorizontal,
+		},
+		{
+			name:      "vertical",
+			direction: imgdiet.FlipVertical,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, _TestDataPath+"/"+_TestValidImageJPG)
+
+			var (
+				width  = image.Width()
+				height = image.Height()
+			)
+
+			if err := image.Flip(tt.direction); err != nil {
+				t.Fatalf("Flip() failed: %v", err)
+			}
+
+			if image.Width() != width || image.Height() != height {
+				t.Errorf("expected %dx%d image, got %dx%d", width, height, image.Width(), image.Height())
+			}
+		})
+	}
+}
+
+func TestImage_Crop(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		left       int
+		top        int
+		width      int
+		height     int
+		wantWidth  int
+		wantHeight int
+		err        error
+	}{
+		{
+			name:       "valid_area",
+			left:       8,
+			top:        4,
+			width:      32,
+			height:     24,
+			wantWidth:  32,
+			wantHeight: 24,
+			err:        nil,
+		},
+		{
+			name:   "area_outside_image",
+			left:   40,
+			top:    0,
+			width:  32,
+			height: 24,
+			err:    imgdiet.ErrInvalidCropArea,
+		},
+		{
+			name:   "empty_area",
+			left:   0,
+			top:    0,
+			width:  0,
+			height: 24,
+			err:    imgdiet.ErrInvalidCropArea,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, _TestDataPath+"/"+_TestBorderedImagePNG)
+
+			err := image.Crop(tt.left, tt.top, tt.width, tt.height)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if image.Width() != tt.wantWidth || image.Height() != tt.wantHeight {
+				t.Errorf("expected %dx%d image, got %dx%d", tt.wantWidth, tt.wantHeight, image.Width(), image.Height())
+			}
+		})
+	}
+}
+
+func TestImage_CropPercent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		left       float64
+		top        float64
+		width      float64
+		height     float64
+		wantWidth  int
+		wantHeight int
+		err        error
+	}{
+		{
+			name:       "center_half",
+			left:       25,
+			top:        25,
+			width:      50,
+			height:     50,
+			wantWidth:  32,
+			wantHeight: 24,
+			err:        nil,
+		},
+		{
+			name:       "whole_image",
+			left:       0,
+			top:        0,
+			width:      100,
+			height:     100,
+			wantWidth:  64,
+			wantHeight: 48,
+			err:        nil,
+		},
+		{
+			name:   "area_outside_image",
+			left:   60,
+			top:    0,
+			width:  50,
+			height: 50,
+			err:    imgdiet.ErrInvalidCropArea,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, _TestDataPath+"/"+_TestBorderedImagePNG)
+
+			err := image.CropPercent(tt.left, tt.top, tt.width, tt.height)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if image.Width() != tt.wantWidth || image.Height() != tt.wantHeight {
+				t.Errorf("expected %dx%d image, got %dx%d", tt.wantWidth, tt.wantHeight, image.Width(), image.Height())
+			}
+		})
+	}
+}
+
+func TestImage_Trim(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		background *imgdiet.Color
+		want       bool
+		wantWidth  int
+		wantHeight int
+	}{
+		{
+			name:       "detected_background",
+			background: nil,
+			want:       true,
+			wantWidth:  32,
+			wantHeight: 20,
+		},
+		{
+			name:       "white_background",
+			background: &imgdiet.Color{R: 255, G: 255, B: 255},
+			want:       true,
+			wantWidth:  32,
+			wantHeight: 20,
+		},
+		{
+			name:       "background_not_present",
+			background: &imgdiet.Color{R: 0, G: 0, B: 0},
+			want:       false,
+			wantWidth:  64,
+			wantHeight: 48,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := openTestImage(t, _TestDataPath+"/"+_TestBorderedImagePNG)
+
+			got, err := image.Trim(imgdiet.DefaultTrimThreshold, tt.background)
+			if err != nil {
+				t.Fatalf("Trim() failed: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+
+			if image.Width() != tt.wantWidth || image.Height() != tt.wantHeight {
+				t.Errorf("expected %dx%d image, got %dx%d", tt.wantWidth, tt.wantHeight, image.Width(), image.Height())
+			}
+		})
+	}
+}
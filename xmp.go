@@ -0,0 +1,505 @@
+package imgdiet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+)
+
+const (
+	// ErrMalformedImage is returned when an encoded image cannot be parsed to
+	// embed metadata into it.
+	ErrMalformedImage xerrors.Error = "malformed image"
+
+	// ErrXMPTooLarge is returned when an XMP packet does not fit in a single
+	// JPEG segment.
+	ErrXMPTooLarge xerrors.Error = "XMP packet is too large"
+)
+
+// List of identifiers used to store XMP packets in the supported formats.
+const (
+	// xmpJPEGNamespace prefixes the XMP packet inside a JPEG APP1 segment.
+	xmpJPEGNamespace string = "http://ns.adobe.com/xap/1.0/\x00"
+
+	// xmpPNGKeyword is the keyword of the PNG iTXt chunk holding the XMP
+	// packet.
+	xmpPNGKeyword string = "XML:com.adobe.xmp"
+)
+
+// rdfNamespace is the namespace of the RDF elements structuring an XMP packet.
+const rdfNamespace string = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// List of JPEG markers relevant to embedding metadata.
+const (
+	jpegMarkerSOI  byte = 0xd8
+	jpegMarkerSOS  byte = 0xda
+	jpegMarkerAPP0 byte = 0xe0
+	jpegMarkerAPP1 byte = 0xe1
+)
+
+// embedXMP returns the given encoded image with its XMP packet replaced by
+// packet. Only JPEG and PNG images are supported; other formats are returned
+// unchanged.
+//
+// Setting the xmp-data field would let libvips write the packet itself, but
+// govips copies the slice header instead of the data when setting blob fields,
+// so the packet is embedded into the encoded image instead.
+func embedXMP(format string, image, packet []byte) ([]byte, error) {
+	switch format {
+	case ImageTypeJPEG:
+		return embedXMPJPEG(image, packet)
+	case ImageTypePNG:
+		return embedXMPPNG(image, packet)
+	default:
+		return image, nil
+	}
+}
+
+// embedXMPJPEG replaces the XMP APP1 segment of a JPEG image, inserting it
+// after the JFIF and EXIF segments as required by the XMP specification.
+func embedXMPJPEG(image, packet []byte) ([]byte, error) {
+	payload := append([]byte(xmpJPEGNamespace), packet...)
+
+	// The segment length includes its own two bytes.
+	if len(payload)+2 > 0xffff {
+		return nil, fmt.Errorf("%w: %d bytes", ErrXMPTooLarge, len(packet))
+	}
+
+	if len(image) < 2 || image[0] != 0xff || image[1] != jpegMarkerSOI {
+		return nil, fmt.Errorf("%w: missing JPEG start of image", ErrMalformedImage)
+	}
+
+	var (
+		out    = make([]byte, 0, len(image)+len(payload)+4)
+		offset = 2
+		insert = -1
+	)
+
+	out = append(out, image[:2]...)
+
+	for {
+		if offset+4 > len(image) || image[offset] != 0xff {
+			return nil, fmt.Errorf("%w: invalid JPEG segment", ErrMalformedImage)
+		}
+
+		marker := image[offset+1]
+		if marker == jpegMarkerSOS {
+			break
+		}
+
+		// The segment length includes its own two bytes, so anything shorter
+		// is invalid.
+		length := int(binary.BigEndian.Uint16(image[offset+2:]))
+		if length < 2 {
+			return nil, fmt.Errorf("%w: invalid JPEG segment length %d", ErrMalformedImage, length)
+		}
+
+		end := offset + 2 + length
+		if end > len(image) {
+			return nil, fmt.Errorf("%w: truncated JPEG segment", ErrMalformedImage)
+		}
+
+		segment := image[offset:end]
+		offset = end
+
+		if marker == jpegMarkerAPP1 && bytes.HasPrefix(segment[4:], []byte(xmpJPEGNamespace)) {
+			continue
+		}
+
+		out = append(out, segment...)
+
+		if marker == jpegMarkerAPP0 || marker == jpegMarkerAPP1 {
+			insert = len(out)
+		}
+	}
+
+	if insert == -1 {
+		insert = 2
+	}
+
+	header := []byte{0xff, jpegMarkerAPP1, 0, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(payload)+2))
+
+	segment := append(header, payload...)
+
+	out = append(out[:insert], append(segment, out[insert:]...)...)
+	out = append(out, image[offset:]...)
+
+	return out, nil
+}
+
+// embedXMPPNG replaces the XMP iTXt chunk of a PNG image, inserting it before
+// the image data.
+func embedXMPPNG(image, packet []byte) ([]byte, error) {
+	signature := []byte("\x89PNG\r\n\x1a\n")
+
+	if !bytes.HasPrefix(image, signature) {
+		return nil, fmt.Errorf("%w: missing PNG signature", ErrMalformedImage)
+	}
+
+	// The iTXt chunk holds the keyword, the compression flag and method, and
+	// empty language and translated keyword fields, all null-separated.
+	data := make([]byte, 0, len(xmpPNGKeyword)+5+len(packet))
+	data = append(data, xmpPNGKeyword...)
+	data = append(data, 0, 0, 0, 0, 0)
+	data = append(data, packet...)
+
+	var (
+		out      = make([]byte, 0, len(image)+len(data)+12)
+		offset   = len(signature)
+		inserted = false
+	)
+
+	out = append(out, signature...)
+
+	for offset < len(image) {
+		if offset+12 > len(image) {
+			return nil, fmt.Errorf("%w: truncated PNG chunk", ErrMalformedImage)
+		}
+
+		length := int(binary.BigEndian.Uint32(image[offset:]))
+		end := offset + 12 + length
+
+		if length < 0 || end > len(image) {
+			return nil, fmt.Errorf("%w: truncated PNG chunk", ErrMalformedImage)
+		}
+
+		var (
+			kind  = string(image[offset+4 : offset+8])
+			chunk = image[offset:end]
+		)
+
+		offset = end
+
+		if kind == "iTXt" && bytes.HasPrefix(chunk[8:], []byte(xmpPNGKeyword+"\x00")) {
+			continue
+		}
+
+		if (kind == "IDAT" || kind == "IEND") && !inserted {
+			out = appendPNGChunk(out, "iTXt", data)
+			inserted = true
+		}
+
+		out = append(out, chunk...)
+	}
+
+	return out, nil
+}
+
+// appendPNGChunk appends a PNG chunk of the given kind and data to out.
+func appendPNGChunk(out []byte, kind string, data []byte) []byte {
+	var header [8]byte
+
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], kind)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	out = append(out, header[:]...)
+	out = append(out, data...)
+	out = binary.BigEndian.AppendUint32(out, crc.Sum32())
+
+	return out
+}
+
+// filterXMP returns the given XMP packet without the properties for which
+// keeps returns false, or nil if no property is left. Properties are named
+// "xmp-", followed by the usual prefix of their namespace and their name, as
+// in "xmp-dc-creator", and only top-level properties are filtered, so
+// structures such as "xmp-xmpMM-History" are kept or removed as a whole.
+//
+// Packets that cannot be parsed are removed entirely, as their properties
+// cannot be checked.
+func filterXMP(packet []byte, keeps func(field string) (bool, error)) ([]byte, error) {
+	var (
+		decoder = xml.NewDecoder(bytes.NewReader(packet))
+		out     = make([]byte, 0, len(packet))
+		offset  int
+		filter  = &xmpFilter{
+			keeps:  keeps,
+			scopes: []map[string]string{{"xml": "http://www.w3.org/XML/1998/namespace"}},
+		}
+	)
+
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, nil //nolint:nilerr // Malformed packets are removed.
+		}
+
+		var (
+			end = int(decoder.InputOffset())
+			raw = packet[offset:end]
+		)
+
+		offset = end
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			skipping := filter.skip > 0
+
+			if raw, err = filter.start(t, raw); err != nil {
+				return nil, err
+			}
+
+			// Removed properties take the indentation preceding them along.
+			if !skipping && filter.skip > 0 {
+				out = bytes.TrimRight(out, " \t\r\n")
+			}
+		case xml.EndElement:
+			if !filter.end() {
+				raw = nil
+			}
+		default:
+			if filter.skip > 0 {
+				raw = nil
+			}
+		}
+
+		out = append(out, raw...)
+	}
+
+	if filter.properties == 0 {
+		return nil, nil
+	}
+
+	return out, nil
+}
+
+// xmpFilter tracks the state of filterXMP while it walks an XMP packet.
+type xmpFilter struct {
+	// keeps reports whether the property with the given name is kept.
+	keeps func(field string) (bool, error)
+
+	// scopes holds the namespaces in scope of each open element, keyed by
+	// their prefix.
+	scopes []map[string]string
+
+	// elements holds the resolved names of the open elements.
+	elements []xml.Name
+
+	// skip is the number of open elements belonging to a removed property,
+	// or 0 outside of one.
+	skip int
+
+	// properties is the number of properties kept so far.
+	properties int
+}
+
+// start opens the given element and returns the raw bytes to write for it,
+// which are nil if the element is removed.
+func (f *xmpFilter) start(element xml.StartElement, raw []byte) ([]byte, error) {
+	scope := xmpScope(f.scopes[len(f.scopes)-1], element.Attr)
+	name := xml.Name{Space: scope[element.Name.Space], Local: element.Name.Local}
+
+	f.scopes = append(f.scopes, scope)
+	f.elements = append(f.elements, name)
+
+	if f.skip > 0 {
+		f.skip++
+
+		return nil, nil
+	}
+
+	if xmpProperty(f.elements[:len(f.elements)-1]) {
+		kept, err := f.keeps(xmpName(name, element.Name.Space))
+		if err != nil {
+			return nil, err
+		}
+
+		if !kept {
+			f.skip = 1
+
+			return nil, nil
+		}
+
+		f.properties++
+	}
+
+	if name.Space != rdfNamespace || name.Local != "Description" || !xmpProperty(f.elements) {
+		return raw, nil
+	}
+
+	description, kept, err := filterXMPDescription(element, raw, scope, f.keeps)
+	if err != nil {
+		return nil, err
+	}
+
+	f.properties += kept
+
+	return description, nil
+}
+
+// end closes the last open element and reports whether its end tag is
+// written.
+func (f *xmpFilter) end() bool {
+	f.scopes = f.scopes[:len(f.scopes)-1]
+	f.elements = f.elements[:len(f.elements)-1]
+
+	if f.skip > 0 {
+		f.skip--
+
+		return false
+	}
+
+	return true
+}
+
+// filterXMPDescription returns the given raw rdf:Description start tag
+// without the properties stored as attributes for which keeps returns false,
+// along with the number of properties kept.
+func filterXMPDescription(
+	element xml.StartElement,
+	raw []byte,
+	scope map[string]string,
+	keeps func(field string) (bool, error),
+) (description []byte, properties int, err error) {
+	var buf bytes.Buffer
+
+	buf.WriteString("<")
+	buf.WriteString(xmpRawName(element.Name))
+
+	for _, attr := range element.Attr {
+		space := scope[attr.Name.Space]
+
+		property := attr.Name.Space != "xmlns" && !(attr.Name.Space == "" && attr.Name.Local == "xmlns") &&
+			space != rdfNamespace && attr.Name.Space != "xml"
+
+		if property {
+			kept, keepErr := keeps(xmpName(xml.Name{Space: space, Local: attr.Name.Local}, attr.Name.Space))
+			if keepErr != nil {
+				return nil, 0, keepErr
+			}
+
+			if !kept {
+				continue
+			}
+
+			properties++
+		}
+
+		buf.WriteString(" ")
+		buf.WriteString(xmpRawName(attr.Name))
+		buf.WriteString("=\"")
+
+		if err = xml.EscapeText(&buf, []byte(attr.Value)); err != nil {
+			return nil, 0, fmt.Errorf("%w", err)
+		}
+
+		buf.WriteString("\"")
+	}
+
+	if bytes.HasSuffix(raw, []byte("/>")) {
+		buf.WriteString("/>")
+	} else {
+		buf.WriteString(">")
+	}
+
+	return buf.Bytes(), properties, nil
+}
+
+// xmpScope returns the namespaces in scope of an element with the given
+// attributes, given the namespaces in scope of its parent.
+func xmpScope(parent map[string]string, attrs []xml.Attr) map[string]string {
+	var scope map[string]string
+
+	for _, attr := range attrs {
+		var prefix string
+
+		switch {
+		case attr.Name.Space == "xmlns":
+			prefix = attr.Name.Local
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			prefix = ""
+		default:
+			continue
+		}
+
+		if scope == nil {
+			scope = make(map[string]string, len(parent)+1)
+
+			for key, value := range parent {
+				scope[key] = value
+			}
+		}
+
+		scope[prefix] = attr.Value
+	}
+
+	if scope == nil {
+		return parent
+	}
+
+	return scope
+}
+
+// xmpProperty reports whether an element nested in the given elements is a
+// top-level XMP property, that is, a child of an rdf:Description element
+// directly under rdf:RDF.
+func xmpProperty(elements []xml.Name) bool {
+	if len(elements) < 2 {
+		return false
+	}
+
+	var (
+		parent      = elements[len(elements)-1]
+		grandparent = elements[len(elements)-2]
+	)
+
+	return parent.Space == rdfNamespace && parent.Local == "Description" &&
+		grandparent.Space == rdfNamespace && grandparent.Local == "RDF"
+}
+
+// xmpName returns the name of the XMP property with the given name, using
+// the usual prefix of well-known namespaces and the given prefix otherwise.
+func xmpName(name xml.Name, prefix string) string {
+	switch name.Space {
+	case "http://purl.org/dc/elements/1.1/":
+		prefix = "dc"
+	case "http://ns.adobe.com/xap/1.0/":
+		prefix = "xmp"
+	case "http://ns.adobe.com/xap/1.0/rights/":
+		prefix = "xmpRights"
+	case "http://ns.adobe.com/xap/1.0/mm/":
+		prefix = "xmpMM"
+	case "http://ns.adobe.com/exif/1.0/":
+		prefix = "exif"
+	case "http://cipa.jp/exif/1.0/":
+		prefix = "exifEX"
+	case "http://ns.adobe.com/exif/1.0/aux/":
+		prefix = "aux"
+	case "http://ns.adobe.com/tiff/1.0/":
+		prefix = "tiff"
+	case "http://ns.adobe.com/photoshop/1.0/":
+		prefix = "photoshop"
+	case "http://ns.adobe.com/camera-raw-settings/1.0/":
+		prefix = "crs"
+	case "http://iptc.org/std/Iptc4xmpCore/1.0/xmlns/":
+		prefix = "Iptc4xmpCore"
+	case "http://iptc.org/std/Iptc4xmpExt/2008-02-29/":
+		prefix = "Iptc4xmpExt"
+	}
+
+	return "xmp-" + prefix + "-" + name.Local
+}
+
+// xmpRawName returns the given unresolved name as written in the packet.
+func xmpRawName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}